@@ -0,0 +1,352 @@
+// Package consumer distribue les événements d'une queue RabbitMQ aux
+// handlers de chaque eventType, après upcast et validation de leur contrat.
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/streadway/amqp"
+)
+
+// ErrMalformed signale un message impossible à décoder : il ne sera jamais rejoué.
+var ErrMalformed = errors.New("malformed event")
+
+// HandlerFunc traite le corps brut d'un événement dont l'eventType est connu.
+type HandlerFunc func(ctx context.Context, body []byte) error
+
+// Decode adapte une fonction typée en HandlerFunc en décodant le JSON du message.
+func Decode[T any](fn func(ctx context.Context, event T) error) HandlerFunc {
+	return func(ctx context.Context, body []byte) error {
+		var event T
+		if err := json.Unmarshal(body, &event); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		return fn(ctx, event)
+	}
+}
+
+// Headers ajoutés aux messages republiés en délai ou en dead-letter.
+const (
+	HeaderValidationError    = "x-validation-error"
+	HeaderError              = "x-error"    // dernière erreur du handler
+	HeaderAttempts           = "x-attempts" // tentatives de traitement échouées
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+)
+
+// Consumer lit une queue durable (déclarée avec ses bindings par la
+// topologie) et distribue chaque message au handler de son eventType.
+//
+// Les messages qui ne respectent pas leur JSON Schema sont republiés sur
+// DeadLetterExchange avec l'erreur de validation en header, puis acquittés.
+//
+// Un message dont le handler échoue est republié dans RetryQueues[0], puis
+// RetryQueues[1]… (queues à TTL qui le renvoient dans Queue) ; après
+// len(RetryQueues)+1 tentatives, il part en dead-letter avec l'erreur et le
+// nombre de tentatives en headers.
+//
+// Les copies republiées le sont avec mandatory=true sur un channel en mode
+// confirm : le message d'origine n'est acquitté qu'une fois la copie
+// confirmée par le broker, et remis en queue sinon (queue de délai ou
+// binding du dead-letter exchange absents, connexion perdue).
+//
+// Avec un Deduplicator, un événement déjà traité par la queue (même eventID,
+// à défaut même MessageId) est acquitté sans appeler son handler.
+type Consumer struct {
+	Queue                string
+	Prefetch             int
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+	RetryQueues          []string
+	Deduplicator         Deduplicator
+
+	handlers map[string]HandlerFunc
+}
+
+// publisher est la partie de *messaging.ConfirmChannel utilisée pour les
+// republications : Publish ne retourne qu'une fois la copie confirmée.
+type publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// New crée un consumer pour la queue donnée.
+func New(queue string) *Consumer {
+	return &Consumer{
+		Queue:    queue,
+		Prefetch: 10,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Configure reprend la définition de la queue dans la topologie :
+// dead-letter et queues de délai.
+func (c *Consumer) Configure(q messaging.Queue) {
+	c.DeadLetterExchange, c.DeadLetterRoutingKey = q.DeadLetterExchange, q.DeadLetterRoutingKey
+	c.RetryQueues = q.RetryQueues()
+}
+
+// Handle enregistre le handler associé à un eventType.
+func (c *Consumer) Handle(eventType string, h HandlerFunc) {
+	c.handlers[eventType] = h
+}
+
+// Run consomme la queue jusqu'à l'annulation du contexte. Une erreur est
+// retournée si le broker ferme la connexion.
+func (c *Consumer) Run(ctx context.Context, conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("ouverture channel : %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.Qos(c.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("qos : %w", err)
+	}
+
+	// les republications passent par leur propre channel en mode confirm
+	pubCh, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("ouverture channel de republication : %w", err)
+	}
+	defer pubCh.Close()
+	republisher, err := messaging.NewConfirmChannel(pubCh)
+	if err != nil {
+		return fmt.Errorf("mode confirm : %w", err)
+	}
+	pubClosed := pubCh.NotifyClose(make(chan *amqp.Error, 1))
+
+	deliveries, err := ch.Consume(c.Queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consommation %q : %w", c.Queue, err)
+	}
+
+	log.Printf("[RabbitMQ] En écoute sur la queue %q", c.Queue)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-pubClosed:
+			return fmt.Errorf("channel de republication fermé par le broker : %v", err)
+		case d, ok := <-deliveries:
+			if !ok {
+				return errors.New("channel de consommation fermé par le broker")
+			}
+			c.dispatch(ctx, republisher, d)
+		}
+	}
+}
+
+// Serve consomme la queue jusqu'à l'annulation du contexte en se
+// reconnectant à RabbitMQ (attente exponentielle plafonnée à 30 s) après
+// chaque coupure.
+func (c *Consumer) Serve(ctx context.Context, url string) {
+	for ctx.Err() == nil {
+		conn, err := dialWithRetry(ctx, url)
+		if err != nil {
+			return
+		}
+
+		if err := c.Run(ctx, conn); err != nil {
+			log.Println("[RabbitMQ] Consommation interrompue :", err)
+		}
+		conn.Close()
+	}
+}
+
+// dialWithRetry tente de se connecter à RabbitMQ jusqu'à l'annulation du contexte.
+func dialWithRetry(ctx context.Context, url string) (*amqp.Connection, error) {
+	delay := time.Second
+	for {
+		conn, err := amqp.Dial(url)
+		if err == nil {
+			return conn, nil
+		}
+		log.Printf("[RabbitMQ] Connexion échouée, nouvel essai dans %s : %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay < 30*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+// dispatch amène l'événement à la version courante de son contrat, le
+// valide, appelle le handler et acquitte le message. Un message invalide part
+// en dead-letter avec la raison du rejet ; un message sans handler est rejeté
+// sans remise en queue ; une erreur de traitement est confiée à fail.
+func (c *Consumer) dispatch(ctx context.Context, ch publisher, d amqp.Delivery) {
+	ctx, span := tracing.StartConsume(ctx, c.Queue, d)
+	defer span.End()
+
+	body, err := events.Upcast(d.Body)
+	if err == nil {
+		err = events.Validate(body)
+	}
+	if err != nil {
+		tracing.SetError(span, err)
+		log.Printf("[Consumer] Message invalide (%s) : %v", d.RoutingKey, err)
+		c.deadLetter(ch, d, amqp.Table{HeaderValidationError: err.Error()})
+		return
+	}
+
+	var envelope events.Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		tracing.SetError(span, err)
+		log.Printf("[Consumer] Message illisible (%s) : %v", d.RoutingKey, err)
+		c.deadLetter(ch, d, amqp.Table{HeaderValidationError: err.Error()})
+		return
+	}
+
+	handler, ok := c.handlers[envelope.EventType]
+	if !ok {
+		log.Printf("[Consumer] Aucun handler pour l'événement %q (%s)", envelope.EventType, d.RoutingKey)
+		_ = d.Nack(false, false)
+		return
+	}
+
+	eventID := envelope.EventID
+	if eventID == "" {
+		eventID = d.MessageId
+	}
+	// les événements publiés par le handler ont cet événement pour cause
+	correlationID, _ := d.Headers[correlation.AMQPCorrelationID].(string)
+	ctx = correlation.FromMessage(ctx, correlationID, eventID)
+	span.SetAttributes(
+		tracing.EventTypeKey.String(envelope.EventType),
+		tracing.CorrelationIDKey.String(correlation.CorrelationID(ctx)),
+	)
+
+	if err := c.handle(ctx, eventID, func(ctx context.Context) error { return handler(ctx, body) }); err != nil {
+		tracing.SetError(span, err)
+		c.fail(ctx, ch, d, envelope.EventType, err)
+		return
+	}
+
+	_ = d.Ack(false)
+}
+
+// fail traite l'échec d'un handler. Tant qu'il reste des tentatives, le
+// message est republié dans la queue de délai suivante ; ensuite, ou pour
+// une erreur ErrMalformed, il part en dead-letter. Sans queue de délai, il
+// est remis en queue une seule fois. Si la republication n'est pas
+// confirmée, le message est remis en queue.
+func (c *Consumer) fail(ctx context.Context, ch publisher, d amqp.Delivery, eventType string, cause error) {
+	correlationID := correlation.CorrelationID(ctx)
+	if len(c.RetryQueues) == 0 {
+		requeue := !d.Redelivered && !errors.Is(cause, ErrMalformed)
+		log.Printf("[Consumer] Échec traitement %s [%s] (requeue=%t) : %v", eventType, correlationID, requeue, cause)
+		_ = d.Nack(false, requeue)
+		return
+	}
+
+	attempts := attemptsOf(d) + 1
+	headers := amqp.Table{HeaderError: cause.Error(), HeaderAttempts: int32(attempts)}
+
+	if attempts <= len(c.RetryQueues) && !errors.Is(cause, ErrMalformed) {
+		retryQueue := c.RetryQueues[attempts-1]
+		log.Printf("[Consumer] Échec traitement %s [%s] (tentative %d/%d), nouvel essai via %s : %v",
+			eventType, correlationID, attempts, len(c.RetryQueues)+1, retryQueue, cause)
+		// l'exchange par défaut route directement vers la queue de ce nom
+		if err := republish(ch, "", retryQueue, d, headers); err != nil {
+			log.Printf("[Consumer] Republication vers %s impossible, message remis en queue : %v", retryQueue, err)
+			_ = d.Nack(false, true)
+			return
+		}
+		_ = d.Ack(false)
+		return
+	}
+
+	log.Printf("[Consumer] Échec définitif %s [%s] après %d tentative(s), envoi en dead-letter : %v",
+		eventType, correlationID, attempts, cause)
+	c.deadLetter(ch, d, headers)
+}
+
+// attemptsOf retourne le nombre de tentatives échouées inscrit dans le
+// message (0 pour une première livraison).
+func attemptsOf(d amqp.Delivery) int {
+	switch n := d.Headers[HeaderAttempts].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// handle appelle fn, au plus une fois par eventID si un Deduplicator est
+// configuré. Un événement sans identifiant est toujours traité.
+func (c *Consumer) handle(ctx context.Context, eventID string, fn func(ctx context.Context) error) error {
+	if c.Deduplicator == nil || eventID == "" {
+		return fn(ctx)
+	}
+
+	duplicate, err := c.Deduplicator.Process(ctx, c.Queue, eventID, fn)
+	if duplicate {
+		log.Printf("[Consumer] Événement %s déjà traité par %q, ignoré", eventID, c.Queue)
+	}
+	return err
+}
+
+// deadLetter republie le message sur le dead-letter exchange avec la raison
+// du rejet en headers, puis l'acquitte. Sans dead-letter exchange configuré,
+// le message est rejeté : les x-arguments de la queue l'envoient alors en
+// dead-letter, sans les headers. Si la republication n'est pas confirmée, il
+// est remis en queue plutôt que perdu.
+func (c *Consumer) deadLetter(ch publisher, d amqp.Delivery, reason amqp.Table) {
+	if c.DeadLetterExchange == "" {
+		_ = d.Nack(false, false)
+		return
+	}
+
+	if err := republish(ch, c.DeadLetterExchange, c.DeadLetterRoutingKey, d, reason); err != nil {
+		log.Printf("[Consumer] Republication en dead-letter impossible, message remis en queue : %v", err)
+		_ = d.Nack(false, true)
+		return
+	}
+	_ = d.Ack(false)
+}
+
+// republish publie une copie du message avec ses headers complétés par
+// extra. L'exchange et la clé de routage d'origine sont conservés en headers
+// à la première republication : un message revenu d'une queue de délai
+// arrive par l'exchange par défaut. La copie est publiée avec
+// mandatory=true : une copie qu'aucune queue ne reçoit est une erreur.
+func republish(ch publisher, exchange, key string, d amqp.Delivery, extra amqp.Table) error {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	if _, ok := headers[HeaderOriginalExchange]; !ok {
+		headers[HeaderOriginalExchange] = d.Exchange
+	}
+	if _, ok := headers[HeaderOriginalRoutingKey]; !ok {
+		headers[HeaderOriginalRoutingKey] = d.RoutingKey
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+
+	return ch.Publish(exchange, key, true, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Body:         d.Body,
+	})
+}
@@ -0,0 +1,456 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing/tracingtest"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// fakeAcknowledger enregistre la décision prise pour un message.
+type fakeAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (f *fakeAcknowledger) Ack(_ uint64, _ bool) error {
+	f.acked = true
+	return nil
+}
+
+func (f *fakeAcknowledger) Nack(_ uint64, _ bool, requeue bool) error {
+	f.nacked, f.requeue = true, requeue
+	return nil
+}
+
+func (f *fakeAcknowledger) Reject(_ uint64, requeue bool) error {
+	f.nacked, f.requeue = true, requeue
+	return nil
+}
+
+// fakeChannel enregistre les messages republiés en délai ou en dead-letter.
+type fakeChannel struct {
+	exchange, key string
+	mandatory     bool
+	published     []amqp.Publishing
+	err           error
+}
+
+func (f *fakeChannel) Publish(exchange, key string, mandatory, _ bool, msg amqp.Publishing) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.exchange, f.key, f.mandatory = exchange, key, mandatory
+	f.published = append(f.published, msg)
+	return nil
+}
+
+func deliverOn(c *Consumer, ch *fakeChannel, body string, redelivered bool) *fakeAcknowledger {
+	ack := &fakeAcknowledger{}
+	c.dispatch(context.Background(), ch, amqp.Delivery{
+		Acknowledger: ack,
+		Exchange:     events.Exchange,
+		RoutingKey:   events.RoutingKeyUserCreated,
+		Redelivered:  redelivered,
+		Body:         []byte(body),
+	})
+	return ack
+}
+
+func deliver(c *Consumer, body string, redelivered bool) *fakeAcknowledger {
+	return deliverOn(c, &fakeChannel{}, body, redelivered)
+}
+
+func newTestConsumer() *Consumer {
+	c := New("notifications")
+	c.DeadLetterExchange, c.DeadLetterRoutingKey = "events.dlx", "notifications"
+	return c
+}
+
+const userCreatedBody = `{"eventType":"UserCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z",
+	"payload":{"userID":"123e4567-e89b-12d3-a456-426614174000","username":"lahoucine","email":"l@example.com","createdAt":"2025-04-15T10:00:00Z"}}`
+
+func TestDispatch_DecodesAndAcks(t *testing.T) {
+	c := New("notifications")
+
+	var received events.UserCreatedEvent
+	c.Handle(events.TypeUserCreated, Decode(func(_ context.Context, e events.UserCreatedEvent) error {
+		received = e
+		return nil
+	}))
+
+	ack := deliver(c, userCreatedBody, false)
+
+	assert.True(t, ack.acked)
+	assert.Equal(t, "lahoucine", received.Payload.Username)
+	assert.Equal(t, "1.0", received.Version)
+}
+
+func TestDispatch_NoHandler(t *testing.T) {
+	c := New("notifications")
+
+	ack := deliver(c, userCreatedBody, false)
+
+	assert.True(t, ack.nacked)
+	assert.False(t, ack.requeue)
+}
+
+func TestDispatch_InvalidEventIsDeadLettered(t *testing.T) {
+	c := newTestConsumer()
+	handled := false
+	c.Handle(events.TypeUserCreated, func(_ context.Context, _ []byte) error {
+		handled = true
+		return nil
+	})
+
+	// email et createdAt manquants : le schéma n'est pas respecté
+	body := `{"eventType":"UserCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z","payload":{"userID":"u-1","username":"l"}}`
+	ch := &fakeChannel{}
+	ack := deliverOn(c, ch, body, false)
+
+	assert.False(t, handled)
+	assert.True(t, ack.acked)
+	assert.Equal(t, "events.dlx", ch.exchange)
+	assert.Equal(t, "notifications", ch.key)
+	assert.True(t, ch.mandatory)
+	require.Len(t, ch.published, 1)
+	assert.Equal(t, body, string(ch.published[0].Body))
+	assert.Contains(t, ch.published[0].Headers[HeaderValidationError], "email")
+	assert.Equal(t, events.RoutingKeyUserCreated, ch.published[0].Headers[HeaderOriginalRoutingKey])
+	assert.Equal(t, events.Exchange, ch.published[0].Headers[HeaderOriginalExchange])
+}
+
+func TestDispatch_InvalidJSONIsDeadLettered(t *testing.T) {
+	c := newTestConsumer()
+	ch := &fakeChannel{}
+
+	ack := deliverOn(c, ch, `{"eventType": `, false)
+
+	assert.True(t, ack.acked)
+	require.Len(t, ch.published, 1)
+	assert.Contains(t, ch.published[0].Headers[HeaderValidationError], "JSON illisible")
+}
+
+func TestDispatch_DeadLetterFailureRequeues(t *testing.T) {
+	c := newTestConsumer()
+
+	ack := deliverOn(c, &fakeChannel{err: amqp.ErrClosed}, `{"eventType":"Inconnu","version":"1.0","payload":{}}`, false)
+
+	assert.False(t, ack.acked)
+	assert.True(t, ack.nacked)
+	assert.True(t, ack.requeue)
+}
+
+func TestDispatch_UnroutableDeadLetterRequeues(t *testing.T) {
+	c := newTestConsumer()
+	unroutable := &messaging.PublishError{Exchange: "events.dlx", RoutingKey: "notifications", Err: messaging.ErrUnroutable}
+
+	ack := deliverOn(c, &fakeChannel{err: unroutable}, `{"eventType":"Inconnu","version":"1.0","payload":{}}`, true)
+
+	assert.False(t, ack.acked)
+	assert.True(t, ack.requeue)
+}
+
+func TestDispatch_HandlerErrorRequeuedOnce(t *testing.T) {
+	c := New("notifications")
+	c.Handle(events.TypeUserCreated, func(_ context.Context, _ []byte) error {
+		return assert.AnError
+	})
+
+	first := deliver(c, userCreatedBody, false)
+	assert.True(t, first.nacked)
+	assert.True(t, first.requeue)
+
+	second := deliver(c, userCreatedBody, true)
+	assert.True(t, second.nacked)
+	assert.False(t, second.requeue)
+}
+
+func TestDispatch_UpcastsOlderVersions(t *testing.T) {
+	c := newTestConsumer()
+
+	var received events.OrderCreatedEvent
+	c.Handle(events.TypeOrderCreated, Decode(func(_ context.Context, e events.OrderCreatedEvent) error {
+		received = e
+		return nil
+	}))
+
+	ack := deliver(c, `{"eventType":"OrderCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z",
+		"payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1}],"totalAmount":10,"orderDate":"2025-04-15T10:00:00Z"}}`, false)
+
+	assert.True(t, ack.acked)
+	assert.Equal(t, "4.0", received.Version)
+	assert.Equal(t, "EUR", received.Payload.Currency)
+}
+
+func TestDispatch_UnsupportedVersionIsDeadLettered(t *testing.T) {
+	c := newTestConsumer()
+	ch := &fakeChannel{}
+
+	ack := deliverOn(c, ch, `{"eventType":"OrderCreated","version":"9.0","payload":{}}`, false)
+
+	assert.True(t, ack.acked)
+	require.Len(t, ch.published, 1)
+	assert.Contains(t, ch.published[0].Headers[HeaderValidationError], "unsupported event version")
+}
+
+// fakeDeduplicator retient les eventIDs traités avec succès.
+type fakeDeduplicator struct {
+	processed map[string]bool
+}
+
+func (f *fakeDeduplicator) Process(ctx context.Context, group, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	key := group + "/" + eventID
+	if f.processed[key] {
+		return true, nil
+	}
+	if err := fn(ctx); err != nil {
+		return false, err
+	}
+	f.processed[key] = true
+	return false, nil
+}
+
+const userCreatedWithIDBody = `{"eventID":"0b8e5f0c-2f7a-4f43-9d6b-1c2d3e4f5a6b","eventType":"UserCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z",
+	"payload":{"userID":"123e4567-e89b-12d3-a456-426614174000","username":"lahoucine","email":"l@example.com","createdAt":"2025-04-15T10:00:00Z"}}`
+
+func TestDispatch_SkipsAlreadyProcessedEvent(t *testing.T) {
+	c := New("notifications")
+	c.Deduplicator = &fakeDeduplicator{processed: map[string]bool{}}
+
+	calls := 0
+	c.Handle(events.TypeUserCreated, func(context.Context, []byte) error {
+		calls++
+		return nil
+	})
+
+	first := deliver(c, userCreatedWithIDBody, false)
+	second := deliver(c, userCreatedWithIDBody, true)
+
+	assert.True(t, first.acked)
+	assert.True(t, second.acked)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDispatch_FailedEventIsProcessedAgain(t *testing.T) {
+	c := New("notifications")
+	c.Deduplicator = &fakeDeduplicator{processed: map[string]bool{}}
+
+	calls := 0
+	c.Handle(events.TypeUserCreated, func(context.Context, []byte) error {
+		calls++
+		if calls == 1 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	first := deliver(c, userCreatedWithIDBody, false)
+	second := deliver(c, userCreatedWithIDBody, true)
+
+	assert.True(t, first.nacked)
+	assert.True(t, first.requeue)
+	assert.True(t, second.acked)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDispatch_DeduplicatesOnMessageIDWithoutEventID(t *testing.T) {
+	dedup := &fakeDeduplicator{processed: map[string]bool{}}
+	c := New("notifications")
+	c.Deduplicator = dedup
+	c.Handle(events.TypeUserCreated, func(context.Context, []byte) error { return nil })
+
+	c.dispatch(context.Background(), &fakeChannel{}, amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		MessageId:    "11111111-1111-1111-1111-111111111111",
+		Body:         []byte(userCreatedBody),
+	})
+
+	assert.True(t, dedup.processed["notifications/11111111-1111-1111-1111-111111111111"])
+}
+
+func TestDispatch_RestoresCorrelation(t *testing.T) {
+	c := New("notifications")
+	var ctx context.Context
+	c.Handle(events.TypeUserCreated, func(handlerCtx context.Context, _ []byte) error {
+		ctx = handlerCtx
+		return nil
+	})
+
+	c.dispatch(context.Background(), &fakeChannel{}, amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		MessageId:    "11111111-1111-1111-1111-111111111111",
+		Headers:      amqp.Table{correlation.AMQPCorrelationID: "corr-1"},
+		Body:         []byte(userCreatedBody),
+	})
+
+	require.NotNil(t, ctx)
+	assert.Equal(t, "corr-1", correlation.CorrelationID(ctx))
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", correlation.CausationID(ctx))
+}
+
+func TestDispatch_StartsCorrelationWithoutHeader(t *testing.T) {
+	c := New("notifications")
+	var ctx context.Context
+	c.Handle(events.TypeUserCreated, func(handlerCtx context.Context, _ []byte) error {
+		ctx = handlerCtx
+		return nil
+	})
+
+	c.dispatch(context.Background(), &fakeChannel{}, amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		MessageId:    "11111111-1111-1111-1111-111111111111",
+		Body:         []byte(userCreatedBody),
+	})
+
+	require.NotNil(t, ctx)
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", correlation.CorrelationID(ctx))
+}
+
+func TestDispatch_ContinuesTrace(t *testing.T) {
+	exporter := tracingtest.Record(t)
+	c := New("notifications")
+	c.Handle(events.TypeUserCreated, func(context.Context, []byte) error { return errors.New("smtp timeout") })
+
+	c.dispatch(context.Background(), &fakeChannel{}, amqp.Delivery{
+		Acknowledger: &fakeAcknowledger{},
+		Headers:      amqp.Table{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		Body:         []byte(userCreatedBody),
+	})
+
+	span := tracingtest.Named(t, exporter, "process notifications")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Equal(t, codes.Error, span.Status.Code)
+}
+
+func newRetryingConsumer(handler HandlerFunc) *Consumer {
+	c := newTestConsumer()
+	c.RetryQueues = []string{"notifications.retry.1000ms", "notifications.retry.2000ms"}
+	c.Handle(events.TypeUserCreated, handler)
+	return c
+}
+
+// redeliver simule le retour d'un message republié par le consumer, après
+// son passage dans une queue de délai.
+func redeliver(c *Consumer, ch *fakeChannel, msg amqp.Publishing) *fakeAcknowledger {
+	ack := &fakeAcknowledger{}
+	c.dispatch(context.Background(), ch, amqp.Delivery{
+		Acknowledger: ack,
+		Exchange:     "",
+		RoutingKey:   "notifications",
+		Headers:      msg.Headers,
+		MessageId:    msg.MessageId,
+		Body:         msg.Body,
+	})
+	return ack
+}
+
+func TestDispatch_HandlerErrorGoesThroughRetryQueues(t *testing.T) {
+	c := newRetryingConsumer(func(context.Context, []byte) error { return assert.AnError })
+
+	ch := &fakeChannel{}
+	first := deliverOn(c, ch, userCreatedBody, false)
+
+	assert.True(t, first.acked)
+	assert.Equal(t, "", ch.exchange)
+	assert.Equal(t, "notifications.retry.1000ms", ch.key)
+	require.Len(t, ch.published, 1)
+	assert.Equal(t, int32(1), ch.published[0].Headers[HeaderAttempts])
+	assert.Equal(t, assert.AnError.Error(), ch.published[0].Headers[HeaderError])
+
+	second := redeliver(c, ch, ch.published[0])
+
+	assert.True(t, second.acked)
+	assert.Equal(t, "notifications.retry.2000ms", ch.key)
+	require.Len(t, ch.published, 2)
+	assert.Equal(t, int32(2), ch.published[1].Headers[HeaderAttempts])
+
+	third := redeliver(c, ch, ch.published[1])
+
+	assert.True(t, third.acked)
+	assert.Equal(t, "events.dlx", ch.exchange)
+	assert.Equal(t, "notifications", ch.key)
+	require.Len(t, ch.published, 3)
+	parked := ch.published[2]
+	assert.Equal(t, int32(3), parked.Headers[HeaderAttempts])
+	assert.Equal(t, assert.AnError.Error(), parked.Headers[HeaderError])
+	assert.Equal(t, events.RoutingKeyUserCreated, parked.Headers[HeaderOriginalRoutingKey])
+	assert.Equal(t, events.Exchange, parked.Headers[HeaderOriginalExchange])
+	assert.Equal(t, userCreatedBody, string(parked.Body))
+}
+
+func TestDispatch_RetrySucceeds(t *testing.T) {
+	calls := 0
+	c := newRetryingConsumer(func(context.Context, []byte) error {
+		calls++
+		if calls == 1 {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	ch := &fakeChannel{}
+	deliverOn(c, ch, userCreatedBody, false)
+	ack := redeliver(c, ch, ch.published[0])
+
+	assert.True(t, ack.acked)
+	assert.Equal(t, 2, calls)
+	assert.Len(t, ch.published, 1)
+}
+
+func TestDispatch_MalformedSkipsRetries(t *testing.T) {
+	c := newRetryingConsumer(func(context.Context, []byte) error { return ErrMalformed })
+
+	ch := &fakeChannel{}
+	ack := deliverOn(c, ch, userCreatedBody, false)
+
+	assert.True(t, ack.acked)
+	assert.Equal(t, "events.dlx", ch.exchange)
+	require.Len(t, ch.published, 1)
+	assert.Equal(t, int32(1), ch.published[0].Headers[HeaderAttempts])
+}
+
+func TestDispatch_RetryPublishFailureRequeues(t *testing.T) {
+	c := newRetryingConsumer(func(context.Context, []byte) error { return assert.AnError })
+
+	ack := deliverOn(c, &fakeChannel{err: amqp.ErrClosed}, userCreatedBody, false)
+
+	assert.True(t, ack.nacked)
+	assert.True(t, ack.requeue)
+}
+
+func TestDispatch_UnroutableRetryRequeues(t *testing.T) {
+	c := newRetryingConsumer(func(context.Context, []byte) error { return assert.AnError })
+	unroutable := &messaging.PublishError{RoutingKey: "notifications.retry.1", Err: messaging.ErrUnroutable}
+
+	// même un message déjà remis en queue n'est pas rejeté : il serait perdu
+	ack := deliverOn(c, &fakeChannel{err: unroutable}, userCreatedBody, true)
+
+	assert.False(t, ack.acked)
+	assert.True(t, ack.requeue)
+}
+
+func TestConfigure_FromTopology(t *testing.T) {
+	topology, err := messaging.DefaultTopology()
+	require.NoError(t, err)
+	q, ok := topology.Queue("notifications")
+	require.True(t, ok)
+
+	c := New("notifications")
+	c.Configure(q)
+
+	assert.Equal(t, "events.dlx", c.DeadLetterExchange)
+	assert.Equal(t, "notifications", c.DeadLetterRoutingKey)
+	assert.Equal(t, q.RetryQueues(), c.RetryQueues)
+	assert.Len(t, c.RetryQueues, q.Retry.MaxAttempts-1)
+}
@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultDedupRetention est la durée de conservation des événements traités.
+const DefaultDedupRetention = 7 * 24 * time.Hour
+
+// Deduplicator garantit qu'un événement n'est traité qu'une fois par groupe
+// de consommateurs (une queue), malgré les redélivrances de RabbitMQ.
+type Deduplicator interface {
+	// Process appelle fn si eventID n'a pas encore été traité par group, et
+	// retourne duplicate=true sans l'appeler sinon. Un événement dont fn
+	// échoue n'est pas marqué traité.
+	Process(ctx context.Context, group, eventID string, fn func(ctx context.Context) error) (duplicate bool, err error)
+}
+
+// SQLDeduplicator enregistre les événements traités dans la table
+// processed_messages :
+//
+//	CREATE TABLE IF NOT EXISTS processed_messages (
+//	  consumer_group TEXT NOT NULL,
+//	  event_id TEXT NOT NULL,
+//	  processed_at TIMESTAMP NOT NULL,
+//	  PRIMARY KEY (consumer_group, event_id)
+//	);
+//
+// La ligne est insérée dans une transaction tenue pendant le traitement :
+// une seconde instance qui reçoit le même événement attend son commit, puis
+// le voit comme déjà traité. La transaction est annulée si le handler échoue.
+// Le handler y accède par Tx pour que ses propres écritures soient validées
+// ou annulées avec le marquage de l'événement.
+type SQLDeduplicator struct {
+	DB *sql.DB
+}
+
+// NewSQLDeduplicator crée un Deduplicator sur la connexion db.
+func NewSQLDeduplicator(db *sql.DB) *SQLDeduplicator {
+	return &SQLDeduplicator{DB: db}
+}
+
+// Process implémente Deduplicator.
+func (s *SQLDeduplicator) Process(ctx context.Context, group, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_messages (consumer_group, event_id, processed_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (consumer_group, event_id) DO NOTHING
+	`, group, eventID, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("processed_messages %s : %w", eventID, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		return true, tx.Commit()
+	}
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return false, err
+	}
+	return false, tx.Commit()
+}
+
+// txKey est la clé de contexte de la transaction de déduplication.
+type txKey struct{}
+
+// Tx retourne la transaction dans laquelle SQLDeduplicator traite
+// l'événement courant, ou nil hors d'une telle transaction.
+func Tx(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// Purge supprime les événements traités avant before.
+func (s *SQLDeduplicator) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM processed_messages WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge processed_messages : %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// RunPurge supprime toutes les interval les événements traités depuis plus
+// de retention, jusqu'à l'annulation du contexte. retention doit dépasser le
+// délai maximal de redélivrance d'un message.
+func (s *SQLDeduplicator) RunPurge(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Purge(ctx, time.Now().UTC().Add(-retention)); err != nil {
+			log.Println("[Consumer] Erreur purge processed_messages :", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLDeduplicator_ProcessesNewEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WithArgs("notifications", "evt-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	called := false
+	duplicate, err := NewSQLDeduplicator(db).Process(context.Background(), "notifications", "evt-1", func(ctx context.Context) error {
+		called = true
+		assert.NotNil(t, Tx(ctx), "le handler doit voir la transaction de déduplication")
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.False(t, duplicate)
+	assert.True(t, called)
+	assert.Nil(t, Tx(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLDeduplicator_SkipsProcessedEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	duplicate, err := NewSQLDeduplicator(db).Process(context.Background(), "notifications", "evt-1", func(context.Context) error {
+		t.Fatal("handler appelé pour un événement déjà traité")
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.True(t, duplicate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLDeduplicator_RollsBackOnHandlerError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	duplicate, err := NewSQLDeduplicator(db).Process(context.Background(), "notifications", "evt-1", func(context.Context) error {
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.False(t, duplicate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
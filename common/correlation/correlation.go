@@ -0,0 +1,109 @@
+// Package correlation suit une requête à travers les services. Le
+// correlation ID est choisi à l'entrée (header HTTP X-Correlation-ID, généré
+// s'il est absent) et reste le même pour tous les événements qui en
+// découlent. Le causation ID d'un événement est l'ID de ce qui l'a provoqué :
+// la requête HTTP, ou l'événement consommé.
+//
+// Les IDs voyagent dans le context.Context, puis dans les headers AMQP
+// x-correlation-id et x-causation-id des messages publiés.
+package correlation
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// HeaderCorrelationID est le header HTTP lu et renvoyé par Middleware.
+	HeaderCorrelationID = "X-Correlation-ID"
+
+	// Headers AMQP des messages publiés.
+	AMQPCorrelationID = "x-correlation-id"
+	AMQPCausationID   = "x-causation-id"
+
+	// MaxIDLength borne la taille d'un ID reçu d'un client.
+	MaxIDLength = 128
+)
+
+type contextKey int
+
+const (
+	correlationKey contextKey = iota
+	causationKey
+)
+
+// WithIDs retourne un contexte portant le correlation ID et le causation ID
+// des messages qui seront publiés.
+func WithIDs(ctx context.Context, correlationID, causationID string) context.Context {
+	ctx = context.WithValue(ctx, correlationKey, correlationID)
+	return context.WithValue(ctx, causationKey, causationID)
+}
+
+// CorrelationID retourne le correlation ID du contexte, ou "".
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey).(string)
+	return id
+}
+
+// CausationID retourne le causation ID du contexte, ou "".
+func CausationID(ctx context.Context) string {
+	id, _ := ctx.Value(causationKey).(string)
+	return id
+}
+
+// Headers retourne les headers AMQP à poser sur un message publié dans ctx ;
+// nil hors de toute requête ou de tout événement.
+func Headers(ctx context.Context) map[string]string {
+	correlationID := CorrelationID(ctx)
+	if correlationID == "" {
+		return nil
+	}
+	headers := map[string]string{AMQPCorrelationID: correlationID}
+	if causationID := CausationID(ctx); causationID != "" {
+		headers[AMQPCausationID] = causationID
+	}
+	return headers
+}
+
+// FromMessage retourne le contexte de traitement d'un message consommé :
+// son correlation ID (à défaut messageID, qui ouvre une nouvelle chaîne) et
+// messageID comme cause des messages qu'il provoquera.
+func FromMessage(ctx context.Context, correlationID, messageID string) context.Context {
+	if correlationID == "" {
+		correlationID = messageID
+	}
+	return WithIDs(ctx, correlationID, messageID)
+}
+
+// Middleware reprend le header X-Correlation-ID de la requête, ou en génère
+// un, le renvoie dans la réponse et le place dans le contexte de la
+// requête, où il est aussi la cause des événements publiés. Le routeur doit
+// avoir ContextWithFallback activé pour que *gin.Context le transmette.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderCorrelationID)
+		if !valid(id) {
+			id = uuid.New().String()
+		}
+
+		c.Request = c.Request.WithContext(WithIDs(c.Request.Context(), id, id))
+		c.Header(HeaderCorrelationID, id)
+		c.Next()
+	}
+}
+
+// valid accepte un ID non vide, court et en ASCII imprimable : il est
+// recopié dans les logs et les headers.
+func valid(id string) bool {
+	if id == "" || len(id) > MaxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// serve exécute une requête à travers Middleware et retourne la réponse et
+// le contexte reçu par le handler.
+func serve(t *testing.T, header string) (*httptest.ResponseRecorder, context.Context) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(Middleware())
+
+	var ctx context.Context
+	r.GET("/", func(c *gin.Context) {
+		ctx = c
+		c.Status(http.StatusNoContent)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(HeaderCorrelationID, header)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, ctx
+}
+
+func TestMiddleware_KeepsIncomingID(t *testing.T) {
+	w, ctx := serve(t, "req-42")
+
+	assert.Equal(t, "req-42", w.Header().Get(HeaderCorrelationID))
+	assert.Equal(t, "req-42", CorrelationID(ctx))
+	assert.Equal(t, "req-42", CausationID(ctx))
+}
+
+func TestMiddleware_GeneratesID(t *testing.T) {
+	w, ctx := serve(t, "")
+
+	id := w.Header().Get(HeaderCorrelationID)
+	assert.NoError(t, uuid.Validate(id))
+	assert.Equal(t, id, CorrelationID(ctx))
+}
+
+func TestMiddleware_ReplacesInvalidID(t *testing.T) {
+	for _, header := range []string{"avec espace", strings.Repeat("a", MaxIDLength+1)} {
+		w, _ := serve(t, header)
+
+		assert.NotEqual(t, header, w.Header().Get(HeaderCorrelationID))
+		assert.NoError(t, uuid.Validate(w.Header().Get(HeaderCorrelationID)))
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	assert.Nil(t, Headers(context.Background()))
+
+	ctx := FromMessage(context.Background(), "corr-1", "event-1")
+	assert.Equal(t, map[string]string{AMQPCorrelationID: "corr-1", AMQPCausationID: "event-1"}, Headers(ctx))
+}
+
+func TestFromMessage_StartsChainWithoutCorrelation(t *testing.T) {
+	ctx := FromMessage(context.Background(), "", "event-1")
+
+	assert.Equal(t, "event-1", CorrelationID(ctx))
+	assert.Equal(t, "event-1", CausationID(ctx))
+}
@@ -0,0 +1,384 @@
+// Package dlq permet d'inspecter les dead-letter queues de la topologie et
+// d'en sortir les messages : les rejouer vers leur exchange et leur clé de
+// routage d'origine, ou les supprimer.
+//
+// Les messages sont lus avec basic.get sans acquittement : ceux qui ne sont
+// ni rejoués ni supprimés sont remis en queue à la fin de l'opération. Une
+// opération ne lit que les messages présents à son début.
+package dlq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/consumer"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrUnknownQueue est retournée pour une queue qui n'est pas une
+	// dead-letter queue de la topologie.
+	ErrUnknownQueue = errors.New("unknown dead-letter queue")
+	// ErrNotFound est retournée lorsqu'aucun message ne porte l'ID demandé.
+	ErrNotFound = errors.New("message not found")
+)
+
+// DefaultListLimit est le nombre de messages listés par défaut.
+const DefaultListLimit = 50
+
+// Queue est une dead-letter queue et la queue dont elle reçoit les rejets.
+type Queue struct {
+	Name     string `json:"name"`
+	Source   string `json:"source"`
+	Messages int    `json:"messages"`
+}
+
+// Message est un message parqué. Headers et Payload ne sont renseignés que
+// par Admin.Get.
+type Message struct {
+	ID                 string          `json:"id"`
+	EventType          string          `json:"event_type,omitempty"`
+	Error              string          `json:"error,omitempty"`
+	Attempts           int             `json:"attempts"`
+	OriginalExchange   string          `json:"original_exchange"`
+	OriginalRoutingKey string          `json:"original_routing_key"`
+	Timestamp          time.Time       `json:"timestamp"`
+	Headers            amqp.Table      `json:"headers,omitempty"`
+	Payload            json.RawMessage `json:"payload,omitempty"`
+}
+
+// Channel est la partie d'un channel AMQP utilisée par Admin. Publish ne
+// retourne qu'une fois le message confirmé par le broker, et retourne
+// messaging.ErrUnroutable pour un message mandatory qu'aucune queue ne reçoit.
+type Channel interface {
+	QueueInspect(name string) (amqp.Queue, error)
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+// Admin opère sur les dead-letter queues déclarées dans la topologie.
+type Admin struct {
+	open    func() (Channel, error)
+	sources map[string]string // dead-letter queue -> queue d'origine
+}
+
+// NewAdmin crée un Admin sur le broker url pour les dead-letter queues de la
+// topologie : les queues liées au dead-letter exchange d'une autre queue.
+func NewAdmin(url string, topology messaging.Topology) *Admin {
+	return newAdmin(func() (Channel, error) { return dial(url) }, topology)
+}
+
+func newAdmin(open func() (Channel, error), topology messaging.Topology) *Admin {
+	sources := make(map[string]string)
+	for _, q := range topology.Queues {
+		if q.DeadLetterExchange == "" {
+			continue
+		}
+		for _, candidate := range topology.Queues {
+			for _, b := range candidate.Bindings {
+				if b.Exchange == q.DeadLetterExchange && b.RoutingKey == q.DeadLetterRoutingKey {
+					sources[candidate.Name] = q.Name
+				}
+			}
+		}
+	}
+	return &Admin{open: open, sources: sources}
+}
+
+// Queues retourne les dead-letter queues et leur nombre de messages.
+func (a *Admin) Queues() ([]Queue, error) {
+	ch, err := a.open()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	names := make([]string, 0, len(a.sources))
+	for name := range a.sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	queues := make([]Queue, 0, len(names))
+	for _, name := range names {
+		q, err := ch.QueueInspect(name)
+		if err != nil {
+			return nil, fmt.Errorf("inspection de %q : %w", name, err)
+		}
+		queues = append(queues, Queue{Name: name, Source: a.sources[name], Messages: q.Messages})
+	}
+	return queues, nil
+}
+
+// List retourne au plus limit messages de la queue, du plus ancien au plus
+// récent.
+func (a *Admin) List(queue string, limit int) ([]Message, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	messages := []Message{}
+	err := a.scan(queue, func(_ Channel, d amqp.Delivery) (bool, bool, error) {
+		messages = append(messages, summary(d))
+		return false, len(messages) >= limit, nil
+	})
+	return messages, err
+}
+
+// Get retourne un message de la queue avec ses headers et son contenu.
+func (a *Admin) Get(queue, id string) (*Message, error) {
+	var found *Message
+	err := a.scan(queue, func(_ Channel, d amqp.Delivery) (bool, bool, error) {
+		if messageID(d) != id {
+			return false, false, nil
+		}
+		m := summary(d)
+		m.Headers = d.Headers
+		if json.Valid(d.Body) {
+			m.Payload = d.Body
+		} else {
+			m.Payload, _ = json.Marshal(string(d.Body))
+		}
+		found = &m
+		return false, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// Replay republie sur leur exchange et leur clé de routage d'origine les
+// messages dont l'ID figure dans ids (tous si ids est vide), sans les
+// headers d'échec, puis les retire de la queue. Il retourne le nombre de
+// messages rejoués.
+//
+// Un message qu'aucune queue ne reçoit plus (binding supprimé, queue
+// renommée) n'est pas retiré : le rejeu s'arrête sur une erreur
+// messaging.ErrUnroutable et le message reste dans la dead-letter queue.
+//
+// Le message est livré à toutes les queues liées à sa clé de routage ; les
+// consommateurs qui l'avaient déjà traité l'ignorent grâce à son eventID.
+func (a *Admin) Replay(queue string, ids []string) (int, error) {
+	replayed := 0
+	err := a.scan(queue, func(ch Channel, d amqp.Delivery) (bool, bool, error) {
+		if !selected(d, ids) {
+			return false, false, nil
+		}
+		exchange, key := original(d)
+		if err := ch.Publish(exchange, key, true, false, amqp.Publishing{
+			Headers:      replayHeaders(d.Headers),
+			ContentType:  d.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    d.MessageId,
+			Timestamp:    d.Timestamp,
+			Body:         d.Body,
+		}); err != nil {
+			return false, true, fmt.Errorf("rejeu de %s vers %s/%s : %w", messageID(d), exchange, key, err)
+		}
+		replayed++
+		return true, false, nil
+	})
+	return replayed, err
+}
+
+// Discard supprime de la queue les messages dont l'ID figure dans ids (tous
+// si ids est vide) et retourne leur nombre.
+func (a *Admin) Discard(queue string, ids []string) (int, error) {
+	discarded := 0
+	err := a.scan(queue, func(_ Channel, d amqp.Delivery) (bool, bool, error) {
+		if !selected(d, ids) {
+			return false, false, nil
+		}
+		discarded++
+		return true, false, nil
+	})
+	return discarded, err
+}
+
+// visitFunc examine un message : remove l'acquitte (il quitte la queue),
+// done arrête le parcours. En cas d'erreur, le message reste en queue.
+type visitFunc func(ch Channel, d amqp.Delivery) (remove, done bool, err error)
+
+// scan parcourt les messages présents dans la queue au début du parcours.
+// Les messages non retirés sont remis en queue à la fin.
+func (a *Admin) scan(queue string, visit visitFunc) error {
+	if _, ok := a.sources[queue]; !ok {
+		return ErrUnknownQueue
+	}
+
+	ch, err := a.open()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueInspect(queue)
+	if err != nil {
+		return fmt.Errorf("inspection de %q : %w", queue, err)
+	}
+
+	var kept []amqp.Delivery
+	defer func() {
+		for _, d := range kept {
+			_ = d.Nack(false, true)
+		}
+	}()
+
+	for i := 0; i < q.Messages; i++ {
+		d, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return fmt.Errorf("lecture de %q : %w", queue, err)
+		}
+		if !ok {
+			return nil
+		}
+
+		remove, done, err := visit(ch, d)
+		if remove {
+			if err := d.Ack(false); err != nil {
+				return fmt.Errorf("acquittement de %s : %w", messageID(d), err)
+			}
+		} else {
+			kept = append(kept, d)
+		}
+		if err != nil || done {
+			return err
+		}
+	}
+	return nil
+}
+
+// summary décrit un message à partir de ses headers et de son enveloppe.
+func summary(d amqp.Delivery) Message {
+	exchange, key := original(d)
+	m := Message{
+		ID:                 messageID(d),
+		Error:              headerString(d.Headers, consumer.HeaderError),
+		OriginalExchange:   exchange,
+		OriginalRoutingKey: key,
+		Timestamp:          d.Timestamp,
+	}
+	if m.Error == "" {
+		m.Error = headerString(d.Headers, consumer.HeaderValidationError)
+	}
+	switch n := d.Headers[consumer.HeaderAttempts].(type) {
+	case int32:
+		m.Attempts = int(n)
+	case int64:
+		m.Attempts = int(n)
+	}
+
+	var envelope struct {
+		EventType string `json:"eventType"`
+	}
+	if json.Unmarshal(d.Body, &envelope) == nil {
+		m.EventType = envelope.EventType
+	}
+	return m
+}
+
+// messageID retourne le MessageId du message, ou à défaut une empreinte de
+// son contenu (messages publiés avant l'ajout des eventIDs).
+func messageID(d amqp.Delivery) string {
+	if d.MessageId != "" {
+		return d.MessageId
+	}
+	sum := sha256.Sum256(d.Body)
+	return "sha256-" + hex.EncodeToString(sum[:8])
+}
+
+func selected(d amqp.Delivery, ids []string) bool {
+	return len(ids) == 0 || slices.Contains(ids, messageID(d))
+}
+
+// original retourne l'exchange et la clé de routage d'origine du message,
+// inscrits en headers par le consumer. Un message rejeté par les x-arguments
+// de sa queue n'a pas ces headers : son origine est alors dans x-death (dont
+// la première entrée est le rejet le plus récent).
+func original(d amqp.Delivery) (string, string) {
+	if key := headerString(d.Headers, consumer.HeaderOriginalRoutingKey); key != "" {
+		return headerString(d.Headers, consumer.HeaderOriginalExchange), key
+	}
+	if deaths, ok := d.Headers["x-death"].([]any); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp.Table); ok {
+			exchange, _ := death["exchange"].(string)
+			if keys, ok := death["routing-keys"].([]any); ok && len(keys) > 0 {
+				key, _ := keys[0].(string)
+				return exchange, key
+			}
+		}
+	}
+	return d.Exchange, d.RoutingKey
+}
+
+// replayHeaders retire les headers d'échec : le message rejoué repart pour
+// toutes ses tentatives.
+func replayHeaders(headers amqp.Table) amqp.Table {
+	replay := amqp.Table{}
+	for k, v := range headers {
+		switch k {
+		case consumer.HeaderError, consumer.HeaderValidationError, consumer.HeaderAttempts,
+			consumer.HeaderOriginalExchange, consumer.HeaderOriginalRoutingKey, "x-death",
+			"x-first-death-exchange", "x-first-death-queue", "x-first-death-reason":
+			continue
+		}
+		replay[k] = v
+	}
+	return replay
+}
+
+func headerString(headers amqp.Table, key string) string {
+	v, _ := headers[key].(string)
+	return v
+}
+
+// amqpChannel est un channel en mode confirm sur sa propre connexion.
+type amqpChannel struct {
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	confirm *messaging.ConfirmChannel
+}
+
+func dial(url string) (Channel, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("connexion RabbitMQ : %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ouverture channel : %w", err)
+	}
+	confirm, err := messaging.NewConfirmChannel(ch)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mode confirm : %w", err)
+	}
+	return &amqpChannel{conn: conn, ch: ch, confirm: confirm}, nil
+}
+
+func (c *amqpChannel) QueueInspect(name string) (amqp.Queue, error) {
+	return c.ch.QueueInspect(name)
+}
+
+func (c *amqpChannel) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	return c.ch.Get(queue, autoAck)
+}
+
+func (c *amqpChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	return c.confirm.Publish(exchange, key, mandatory, immediate, msg)
+}
+
+func (c *amqpChannel) Close() error {
+	return c.conn.Close()
+}
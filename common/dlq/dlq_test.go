@@ -0,0 +1,324 @@
+package dlq
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/consumer"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker est un Channel en mémoire : un message lu sans être acquitté
+// revient en tête de queue à la fermeture du channel.
+type fakeBroker struct {
+	queues     map[string][]amqp.Delivery
+	unacked    map[uint64]amqp.Delivery
+	order      []uint64
+	published  []publication
+	publishErr error
+	routes     map[string]bool // clés de routage liées à une queue (nil : toutes)
+	tag        uint64
+}
+
+type publication struct {
+	exchange, key string
+	msg           amqp.Publishing
+}
+
+func newFakeBroker(queue string, messages ...amqp.Delivery) *fakeBroker {
+	return &fakeBroker{queues: map[string][]amqp.Delivery{queue: messages}, unacked: map[uint64]amqp.Delivery{}}
+}
+
+func (f *fakeBroker) QueueInspect(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name, Messages: len(f.queues[name])}, nil
+}
+
+func (f *fakeBroker) Get(queue string, _ bool) (amqp.Delivery, bool, error) {
+	if len(f.queues[queue]) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+	d := f.queues[queue][0]
+	f.queues[queue] = f.queues[queue][1:]
+	f.tag++
+	d.DeliveryTag, d.Acknowledger, d.RoutingKey = f.tag, f, queue
+	f.unacked[f.tag] = d
+	f.order = append(f.order, f.tag)
+	return d, true, nil
+}
+
+func (f *fakeBroker) Publish(exchange, key string, mandatory, _ bool, msg amqp.Publishing) error {
+	if f.publishErr != nil {
+		return f.publishErr
+	}
+	if f.routes != nil && !f.routes[key] {
+		if mandatory {
+			// basic.return puis basic.ack, comme RabbitMQ
+			return &messaging.PublishError{Exchange: exchange, RoutingKey: key, Reason: "NO_ROUTE", Err: messaging.ErrUnroutable}
+		}
+		return nil // confirmé puis perdu
+	}
+	f.published = append(f.published, publication{exchange, key, msg})
+	return nil
+}
+
+func (f *fakeBroker) Close() error {
+	var requeued []amqp.Delivery
+	for _, tag := range f.order {
+		if d, ok := f.unacked[tag]; ok {
+			requeued = append(requeued, d)
+			delete(f.unacked, tag)
+		}
+	}
+	for i := len(requeued) - 1; i >= 0; i-- {
+		d := requeued[i]
+		f.queues[d.RoutingKey] = append([]amqp.Delivery{d}, f.queues[d.RoutingKey]...)
+	}
+	f.order = nil
+	return nil
+}
+
+func (f *fakeBroker) Ack(tag uint64, _ bool) error {
+	delete(f.unacked, tag)
+	return nil
+}
+
+func (f *fakeBroker) Nack(tag uint64, _ bool, requeue bool) error {
+	if !requeue {
+		delete(f.unacked, tag)
+	}
+	return nil
+}
+
+func (f *fakeBroker) Reject(tag uint64, requeue bool) error {
+	return f.Nack(tag, false, requeue)
+}
+
+// ids retourne les MessageId restant dans la queue.
+func (f *fakeBroker) ids(queue string) []string {
+	var ids []string
+	for _, d := range f.queues[queue] {
+		ids = append(ids, d.MessageId)
+	}
+	return ids
+}
+
+func parked(id, routingKey string) amqp.Delivery {
+	return amqp.Delivery{
+		MessageId:   id,
+		ContentType: "application/json",
+		Headers: amqp.Table{
+			consumer.HeaderError:              "smtp timeout",
+			consumer.HeaderAttempts:           int32(5),
+			consumer.HeaderOriginalExchange:   "events",
+			consumer.HeaderOriginalRoutingKey: routingKey,
+		},
+		Body: []byte(`{"eventID":"` + id + `","eventType":"UserCreated","version":"1.0"}`),
+	}
+}
+
+func newTestAdmin(t *testing.T, broker *fakeBroker) *Admin {
+	topology, err := messaging.DefaultTopology()
+	require.NoError(t, err)
+	return newAdmin(func() (Channel, error) { return broker, nil }, topology)
+}
+
+func TestAdmin_QueuesFromTopology(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"))
+	admin := newTestAdmin(t, broker)
+
+	queues, err := admin.Queues()
+	require.NoError(t, err)
+
+	assert.Contains(t, queues, Queue{Name: "notifications.dlq", Source: "notifications", Messages: 1})
+	assert.Contains(t, queues, Queue{Name: "commandes.users.dlq", Source: "commandes.users", Messages: 0})
+}
+
+func TestAdmin_ListLeavesMessagesInQueue(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "commande.created"))
+	admin := newTestAdmin(t, broker)
+
+	messages, err := admin.List("notifications.dlq", 10)
+	require.NoError(t, err)
+
+	require.Len(t, messages, 2)
+	assert.Equal(t, "m-1", messages[0].ID)
+	assert.Equal(t, "UserCreated", messages[0].EventType)
+	assert.Equal(t, "smtp timeout", messages[0].Error)
+	assert.Equal(t, 5, messages[0].Attempts)
+	assert.Equal(t, "events", messages[0].OriginalExchange)
+	assert.Equal(t, "commande.created", messages[1].OriginalRoutingKey)
+	assert.Nil(t, messages[0].Payload)
+	assert.Equal(t, []string{"m-1", "m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_ListRespectsLimit(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.created"))
+
+	messages, err := newTestAdmin(t, broker).List("notifications.dlq", 1)
+	require.NoError(t, err)
+
+	assert.Len(t, messages, 1)
+	assert.Equal(t, []string{"m-1", "m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_UnknownQueue(t *testing.T) {
+	_, err := newTestAdmin(t, newFakeBroker("notifications")).List("notifications", 10)
+
+	assert.ErrorIs(t, err, ErrUnknownQueue)
+}
+
+func TestAdmin_Get(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.created"))
+	admin := newTestAdmin(t, broker)
+
+	m, err := admin.Get("notifications.dlq", "m-2")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"eventID":"m-2","eventType":"UserCreated","version":"1.0"}`, string(m.Payload))
+	assert.Equal(t, "smtp timeout", m.Headers[consumer.HeaderError])
+
+	_, err = admin.Get("notifications.dlq", "inconnu")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, []string{"m-1", "m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_ReplaySelected(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "commande.created"))
+	admin := newTestAdmin(t, broker)
+
+	n, err := admin.Replay("notifications.dlq", []string{"m-2"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, n)
+	require.Len(t, broker.published, 1)
+	assert.Equal(t, "events", broker.published[0].exchange)
+	assert.Equal(t, "commande.created", broker.published[0].key)
+	assert.Equal(t, "m-2", broker.published[0].msg.MessageId)
+	assert.Empty(t, broker.published[0].msg.Headers)
+	assert.Equal(t, []string{"m-1"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_ReplayAll(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "commande.created"))
+
+	n, err := newTestAdmin(t, broker).Replay("notifications.dlq", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, n)
+	assert.Empty(t, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_ReplayFromXDeath(t *testing.T) {
+	// rejeté par les x-arguments de la queue, sans les headers du consumer
+	d := amqp.Delivery{MessageId: "m-1", Headers: amqp.Table{"x-death": []any{
+		amqp.Table{"queue": "notifications", "exchange": "events", "routing-keys": []any{"user.deleted"}},
+	}}, Body: []byte(`{}`)}
+	broker := newFakeBroker("notifications.dlq", d)
+
+	_, err := newTestAdmin(t, broker).Replay("notifications.dlq", []string{"m-1"})
+	require.NoError(t, err)
+
+	require.Len(t, broker.published, 1)
+	assert.Equal(t, "events", broker.published[0].exchange)
+	assert.Equal(t, "user.deleted", broker.published[0].key)
+}
+
+func TestAdmin_ReplayFailureKeepsMessage(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.created"))
+	broker.publishErr = errors.New("channel fermé")
+
+	n, err := newTestAdmin(t, broker).Replay("notifications.dlq", nil)
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, []string{"m-1", "m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_ReplayUnroutableKeepsMessage(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.renamed"))
+	broker.routes = map[string]bool{"user.created": true}
+
+	n, err := newTestAdmin(t, broker).Replay("notifications.dlq", nil)
+
+	assert.ErrorIs(t, err, messaging.ErrUnroutable)
+	assert.Equal(t, 1, n)
+	require.Len(t, broker.published, 1)
+	assert.Equal(t, "m-1", broker.published[0].msg.MessageId)
+	assert.Equal(t, []string{"m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestAdmin_Discard(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.created"))
+
+	n, err := newTestAdmin(t, broker).Discard("notifications.dlq", []string{"m-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, n)
+	assert.Empty(t, broker.published)
+	assert.Equal(t, []string{"m-2"}, broker.ids("notifications.dlq"))
+}
+
+func TestMessageID_FallsBackToBodyHash(t *testing.T) {
+	id := messageID(amqp.Delivery{Body: []byte(`{"a":1}`)})
+
+	assert.True(t, strings.HasPrefix(id, "sha256-"))
+	assert.Equal(t, id, messageID(amqp.Delivery{Body: []byte(`{"a":1}`)}))
+}
+
+func setupRouter(admin *Admin) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	admin.Register(r.Group("/admin"))
+	return r
+}
+
+func request(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlers(t *testing.T) {
+	broker := newFakeBroker("notifications.dlq", parked("m-1", "user.created"), parked("m-2", "user.created"))
+	r := setupRouter(newTestAdmin(t, broker))
+
+	w := request(r, http.MethodGet, "/admin/dlq/notifications.dlq/messages?limit=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"m-1"`)
+	assert.NotContains(t, w.Body.String(), `"id":"m-2"`)
+
+	w = request(r, http.MethodGet, "/admin/dlq/notifications.dlq/messages/m-2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"payload":{"eventID":"m-2"`)
+
+	w = request(r, http.MethodGet, "/admin/dlq/notifications.dlq/messages/inconnu", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = request(r, http.MethodGet, "/admin/dlq/notifications/messages", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = request(r, http.MethodPost, "/admin/dlq/notifications.dlq/replay", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request(r, http.MethodPost, "/admin/dlq/notifications.dlq/replay", `{"ids":["m-1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"replayed":1}`, w.Body.String())
+
+	broker.routes = map[string]bool{}
+	w = request(r, http.MethodPost, "/admin/dlq/notifications.dlq/replay", `{"ids":["m-2"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, []string{"m-2"}, broker.ids("notifications.dlq"))
+
+	w = request(r, http.MethodPost, "/admin/dlq/notifications.dlq/discard", `{"all":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"discarded":1}`, w.Body.String())
+	assert.Empty(t, broker.ids("notifications.dlq"))
+}
@@ -0,0 +1,135 @@
+package dlq
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/gin-gonic/gin"
+)
+
+// SelectionInput désigne les messages à rejouer ou à supprimer : une liste
+// d'IDs, ou tous les messages de la queue avec all.
+type SelectionInput struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+// ListMessagesQuery représente les paramètres de GET /dlq/:queue/messages.
+type ListMessagesQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// Register déclare les routes d'administration des dead-letter queues sur
+// r, qui doit être réservé aux administrateurs :
+//
+//	GET  /dlq                       queues et nombre de messages
+//	GET  /dlq/:queue/messages       messages parqués et leur erreur
+//	GET  /dlq/:queue/messages/:id   un message, ses headers et son contenu
+//	POST /dlq/:queue/replay         rejeu vers la clé de routage d'origine
+//	POST /dlq/:queue/discard        suppression
+func (a *Admin) Register(r gin.IRouter) {
+	r.GET("/dlq", a.listQueuesHandler)
+	r.GET("/dlq/:queue/messages", a.listMessagesHandler)
+	r.GET("/dlq/:queue/messages/:id", a.getMessageHandler)
+	r.POST("/dlq/:queue/replay", a.replayHandler)
+	r.POST("/dlq/:queue/discard", a.discardHandler)
+}
+
+func (a *Admin) listQueuesHandler(c *gin.Context) {
+	queues, err := a.Queues()
+	if err != nil {
+		log.Println("[DLQ] Erreur inspection des queues :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not inspect dead-letter queues"})
+		return
+	}
+	c.JSON(http.StatusOK, queues)
+}
+
+func (a *Admin) listMessagesHandler(c *gin.Context) {
+	var query ListMessagesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messages, err := a.List(c.Param("queue"), query.Limit)
+	if respondError(c, err, "could not list dead-lettered messages") {
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+func (a *Admin) getMessageHandler(c *gin.Context) {
+	message, err := a.Get(c.Param("queue"), c.Param("id"))
+	if respondError(c, err, "could not read dead-lettered message") {
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+func (a *Admin) replayHandler(c *gin.Context) {
+	ids, ok := bindSelection(c)
+	if !ok {
+		return
+	}
+
+	n, err := a.Replay(c.Param("queue"), ids)
+	if n > 0 {
+		log.Printf("[DLQ] %d message(s) rejoué(s) depuis %s", n, c.Param("queue"))
+	}
+	if respondError(c, err, "could not replay dead-lettered messages") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replayed": n})
+}
+
+func (a *Admin) discardHandler(c *gin.Context) {
+	ids, ok := bindSelection(c)
+	if !ok {
+		return
+	}
+
+	n, err := a.Discard(c.Param("queue"), ids)
+	if n > 0 {
+		log.Printf("[DLQ] %d message(s) supprimé(s) de %s", n, c.Param("queue"))
+	}
+	if respondError(c, err, "could not discard dead-lettered messages") {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"discarded": n})
+}
+
+// bindSelection lit les messages visés ; une liste vide sans all est
+// refusée, pour qu'une requête incomplète ne vide pas la queue.
+func bindSelection(c *gin.Context) ([]string, bool) {
+	var input SelectionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if input.All == (len(input.IDs) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either ids or all must be set"})
+		return nil, false
+	}
+	return input.IDs, true
+}
+
+// respondError répond à une erreur de l'Admin et indique si elle a été
+// traitée.
+func respondError(c *gin.Context, err error, message string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrUnknownQueue), errors.Is(err, ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, messaging.ErrUnroutable):
+		// le message est resté dans la dead-letter queue
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[DLQ] Erreur %s : %v", c.Param("queue"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+	return true
+}
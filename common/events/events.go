@@ -1,15 +1,67 @@
 // File: common/events/events.go
 package events
 
-import "time"
-
-// BaseEvent définit les champs communs à tous les événements.
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+	"github.com/google/uuid"
+)
+
+// Exchange est l'exchange de type topic sur lequel transitent tous les événements.
+const Exchange = "events"
+
+// Valeurs possibles du champ eventType.
+const (
+	TypeUserCreated           = "UserCreated"
+	TypeUserUpdated           = "UserUpdated"
+	TypeUserDeleted           = "UserDeleted"
+	TypeOrderCreated          = "OrderCreated"
+	TypeOrderUpdated          = "OrderUpdated"
+	TypeOrderCanceled         = "OrderCanceled"
+	TypeNotificationTriggered = "NotificationTriggered"
+)
+
+// Clés de routage utilisées sur l'exchange Exchange.
+const (
+	RoutingKeyUserCreated           = "user.created"
+	RoutingKeyUserUpdated           = "user.updated"
+	RoutingKeyUserDeleted           = "user.deleted"
+	RoutingKeyOrderCreated          = "commande.created"
+	RoutingKeyOrderUpdated          = "commande.updated"
+	RoutingKeyOrderCanceled         = "commande.canceled"
+	RoutingKeyNotificationTriggered = "notification.triggered"
+)
+
+// BaseEvent définit les champs communs à tous les événements. EventID
+// identifie une émission : un message redélivré par RabbitMQ garde le même
+// EventID, ce qui permet aux consommateurs de l'ignorer. Il est absent des
+// événements émis avant son introduction.
 type BaseEvent struct {
+	EventID   string    `json:"eventID,omitempty"`
 	EventType string    `json:"eventType"`
 	Version   string    `json:"version"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// NewBaseEvent retourne l'en-tête d'un événement émis maintenant, avec un
+// nouvel EventID, dans la version courante de son eventType.
+func NewBaseEvent(eventType string) BaseEvent {
+	return BaseEvent{
+		EventID:   uuid.New().String(),
+		EventType: eventType,
+		Version:   CurrentVersion(eventType),
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// Envelope permet de lire l'en-tête d'un événement sans décoder son payload.
+type Envelope struct {
+	BaseEvent
+	Payload json.RawMessage `json:"payload"`
+}
+
 // --- Événements du Service Utilisateurs ---
 
 // UserCreatedPayload représente le contenu d'un UserCreated.
@@ -54,19 +106,26 @@ type UserDeletedEvent struct {
 
 // --- Événements du Service Commandes ---
 
-// OrderItem représente un article dans une commande.
+// OrderItem représente un article dans une commande (UnitPrice depuis les
+// versions 3.0 de OrderCreated et OrderUpdated, en unités mineures depuis
+// les versions 4.0).
 type OrderItem struct {
-	ProductID string `json:"productID"`
-	Quantity  int    `json:"quantity"`
+	ProductID string       `json:"productID"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice money.Amount `json:"unitPrice"`
 }
 
-// OrderCreatedPayload représente le contenu d'un OrderCreated.
+// OrderCreatedPayload représente le contenu d'un OrderCreated (version 2.0 :
+// ajout de Currency ; version 3.0 : prix unitaire des articles ; version
+// 4.0 : montants en unités mineures de Currency ; les messages antérieurs
+// sont complétés par Upcast).
 type OrderCreatedPayload struct {
-	OrderID     string      `json:"orderID"`
-	UserID      string      `json:"userID"`
-	Items       []OrderItem `json:"items"`
-	TotalAmount float64     `json:"totalAmount"`
-	OrderDate   time.Time   `json:"orderDate"`
+	OrderID     string       `json:"orderID"`
+	UserID      string       `json:"userID"`
+	Items       []OrderItem  `json:"items"`
+	TotalAmount money.Amount `json:"totalAmount"`
+	Currency    string       `json:"currency"`
+	OrderDate   time.Time    `json:"orderDate"`
 }
 
 // OrderCreatedEvent représente l'événement de création d'une commande.
@@ -75,14 +134,19 @@ type OrderCreatedEvent struct {
 	Payload OrderCreatedPayload `json:"payload"`
 }
 
-// OrderUpdatedPayload représente le contenu d'un OrderUpdated.
+// OrderUpdatedPayload représente le contenu d'un OrderUpdated (version 2.0 :
+// ajout de Status, vide pour les messages 1.0 complétés par Upcast ; version
+// 3.0 : prix unitaire des articles ; version 4.0 : Currency et montants en
+// unités mineures).
 type OrderUpdatedPayload struct {
-	OrderID     string      `json:"orderID"`
-	UserID      string      `json:"userID"`
-	Items       []OrderItem `json:"items"`
-	TotalAmount float64     `json:"totalAmount"`
-	OrderDate   time.Time   `json:"orderDate"`
-	UpdatedAt   time.Time   `json:"updatedAt"`
+	OrderID     string       `json:"orderID"`
+	UserID      string       `json:"userID"`
+	Items       []OrderItem  `json:"items"`
+	TotalAmount money.Amount `json:"totalAmount"`
+	Currency    string       `json:"currency"`
+	Status      string       `json:"status"`
+	OrderDate   time.Time    `json:"orderDate"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
 }
 
 // OrderUpdatedEvent représente l'événement de mise à jour d'une commande.
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed *.schema.json
+var schemaFS embed.FS
+
+// SchemaFiles associe chaque eventType à son fichier JSON Schema.
+var SchemaFiles = map[string]string{
+	TypeUserCreated:           "user_created.schema.json",
+	TypeUserUpdated:           "user_updated.schema.json",
+	TypeUserDeleted:           "user_deleted.schema.json",
+	TypeOrderCreated:          "order_created.schema.json",
+	TypeOrderUpdated:          "order_updated.schema.json",
+	TypeOrderCanceled:         "order_canceled.schema.json",
+	TypeNotificationTriggered: "notification_triggered.schema.json",
+}
+
+// ErrInvalidEvent est la cause de toute *ValidationError.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// ValidationError décrit un événement qui ne respecte pas son contrat.
+type ValidationError struct {
+	EventType string
+	Reason    string
+}
+
+func (e *ValidationError) Error() string {
+	if e.EventType == "" {
+		return fmt.Sprintf("%v: %s", ErrInvalidEvent, e.Reason)
+	}
+	return fmt.Sprintf("%v %s: %s", ErrInvalidEvent, e.EventType, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidEvent
+}
+
+var (
+	compileOnce sync.Once
+	schemas     map[string]*jsonschema.Schema
+	compileErr  error
+)
+
+// compileSchemas compile une seule fois les schémas embarqués.
+func compileSchemas() (map[string]*jsonschema.Schema, error) {
+	compileOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiler.Draft = jsonschema.Draft7
+		compiler.AssertFormat = true
+
+		compiled := make(map[string]*jsonschema.Schema, len(SchemaFiles))
+		for eventType, file := range SchemaFiles {
+			data, err := schemaFS.ReadFile(file)
+			if err != nil {
+				compileErr = err
+				return
+			}
+			if err := compiler.AddResource(file, bytes.NewReader(data)); err != nil {
+				compileErr = fmt.Errorf("schéma %s : %w", file, err)
+				return
+			}
+			schema, err := compiler.Compile(file)
+			if err != nil {
+				compileErr = fmt.Errorf("schéma %s : %w", file, err)
+				return
+			}
+			compiled[eventType] = schema
+		}
+		schemas = compiled
+	})
+	return schemas, compileErr
+}
+
+// Validate vérifie un événement sérialisé contre le schéma de son eventType.
+// Les erreurs de contrat sont des *ValidationError (errors.Is ErrInvalidEvent).
+func Validate(body []byte) error {
+	compiled, err := compileSchemas()
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return &ValidationError{Reason: "JSON illisible : " + err.Error()}
+	}
+
+	eventType, _ := lookupEventType(doc)
+	schema, ok := compiled[eventType]
+	if !ok {
+		return &ValidationError{EventType: eventType, Reason: "eventType inconnu"}
+	}
+
+	if err := schema.Validate(doc); err != nil {
+		return &ValidationError{EventType: eventType, Reason: validationReason(err)}
+	}
+	return nil
+}
+
+func lookupEventType(doc any) (string, bool) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	eventType, ok := obj["eventType"].(string)
+	return eventType, ok
+}
+
+// validationReason résume une erreur jsonschema sur une ligne par violation.
+func validationReason(err error) string {
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err.Error()
+	}
+
+	var reasons []string
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			location := e.InstanceLocation
+			if location == "" {
+				location = "/"
+			}
+			reasons = append(reasons, location+" : "+e.Message)
+			return
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(verr)
+
+	var buf bytes.Buffer
+	for i, r := range reasons {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(r)
+	}
+	return buf.String()
+}
@@ -0,0 +1,187 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleTime = time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC)
+
+// samples contient un événement valide par eventType, construit depuis les
+// structs Go : si une struct évolue sans son schéma, la validation échoue.
+var samples = map[string]any{
+	TypeUserCreated: UserCreatedEvent{
+		BaseEvent: BaseEvent{EventID: "0b8e5f0c-2f7a-4f43-9d6b-1c2d3e4f5a6b", EventType: TypeUserCreated, Version: "1.0", Timestamp: sampleTime},
+		Payload:   UserCreatedPayload{UserID: "u-1", Username: "lahoucine", Email: "l@example.com", CreatedAt: sampleTime},
+	},
+	TypeUserUpdated: UserUpdatedEvent{
+		BaseEvent: BaseEvent{EventType: TypeUserUpdated, Version: "1.0", Timestamp: sampleTime},
+		Payload:   UserUpdatedPayload{UserID: "u-1", Username: "lahoucine", Email: "l@example.com", UpdatedAt: sampleTime},
+	},
+	TypeUserDeleted: UserDeletedEvent{
+		BaseEvent: BaseEvent{EventType: TypeUserDeleted, Version: "1.0", Timestamp: sampleTime},
+		Payload:   UserDeletedPayload{UserID: "u-1", DeletedAt: sampleTime},
+	},
+	TypeOrderCreated: OrderCreatedEvent{
+		BaseEvent: BaseEvent{EventType: TypeOrderCreated, Version: "4.0", Timestamp: sampleTime},
+		Payload: OrderCreatedPayload{
+			OrderID: "c-1", UserID: "u-1", TotalAmount: 4250, Currency: "EUR", OrderDate: sampleTime,
+			Items: []OrderItem{{ProductID: "p-1", Quantity: 2, UnitPrice: 2125}},
+		},
+	},
+	TypeOrderUpdated: OrderUpdatedEvent{
+		BaseEvent: BaseEvent{EventType: TypeOrderUpdated, Version: "4.0", Timestamp: sampleTime},
+		Payload: OrderUpdatedPayload{
+			OrderID: "c-1", UserID: "u-1", TotalAmount: 4250, Currency: "EUR", Status: "confirmee", OrderDate: sampleTime, UpdatedAt: sampleTime,
+			Items: []OrderItem{{ProductID: "p-1", Quantity: 2, UnitPrice: 2125}},
+		},
+	},
+	TypeOrderCanceled: OrderCanceledEvent{
+		BaseEvent: BaseEvent{EventType: TypeOrderCanceled, Version: "1.0", Timestamp: sampleTime},
+		Payload:   OrderCanceledPayload{OrderID: "c-1", UserID: "u-1", CanceledAt: sampleTime, Reason: "rupture de stock"},
+	},
+	TypeNotificationTriggered: NotificationTriggeredEvent{
+		BaseEvent: BaseEvent{EventType: TypeNotificationTriggered, Version: "1.0", Timestamp: sampleTime},
+		Payload:   NotificationTriggeredPayload{NotificationID: "n-1", UserID: "u-1", Message: "Bienvenue", CreatedAt: sampleTime},
+	},
+}
+
+func TestSchemas_MatchGoStructs(t *testing.T) {
+	require.Len(t, samples, len(SchemaFiles))
+
+	for eventType, file := range SchemaFiles {
+		t.Run(eventType, func(t *testing.T) {
+			data, err := schemaFS.ReadFile(file)
+			require.NoError(t, err)
+
+			var schema map[string]any
+			require.NoError(t, json.Unmarshal(data, &schema))
+
+			sample, ok := samples[eventType]
+			require.True(t, ok, "aucun exemple pour %s", eventType)
+			assertSameFields(t, "", reflect.TypeOf(sample), schema)
+
+			body, err := json.Marshal(sample)
+			require.NoError(t, err)
+			assert.NoError(t, Validate(body))
+		})
+	}
+}
+
+// assertSameFields vérifie que les champs JSON d'une struct correspondent aux
+// propriétés de l'objet décrit par le schéma, récursivement. Seuls les champs
+// omitempty sont facultatifs.
+func assertSameFields(t *testing.T, path string, typ reflect.Type, schema map[string]any) {
+	t.Helper()
+
+	properties, _ := schema["properties"].(map[string]any)
+	fields, optional := jsonFields(typ)
+	required := make(map[string]reflect.Type)
+	for name, field := range fields {
+		if !optional[name] {
+			required[name] = field
+		}
+	}
+
+	assert.Equal(t, sortedKeys(fields), sortedKeys(properties), "propriétés de %q", path)
+	assert.ElementsMatch(t, sortedKeys(required), schema["required"], "champs requis de %q", path)
+
+	for name, field := range fields {
+		sub, ok := properties[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Struct && field != reflect.TypeOf(time.Time{}):
+			assertSameFields(t, path+"/"+name, field, sub)
+		case field.Kind() == reflect.Slice && field.Elem().Kind() == reflect.Struct:
+			items, _ := sub["items"].(map[string]any)
+			assertSameFields(t, path+"/"+name+"[]", field.Elem(), items)
+		}
+	}
+}
+
+// jsonFields retourne les champs sérialisés d'une struct, structs anonymes
+// aplaties, et l'ensemble de ceux marqués omitempty.
+func jsonFields(typ reflect.Type) (map[string]reflect.Type, map[string]bool) {
+	fields := make(map[string]reflect.Type)
+	optional := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			subFields, subOptional := jsonFields(f.Type)
+			for name, sub := range subFields {
+				fields[name] = sub
+				optional[name] = subOptional[name]
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+		optional[name] = slices.Contains(strings.Split(opts, ","), "omitempty")
+	}
+	return fields, optional
+}
+
+func sortedKeys[V any](m map[string]V) []any {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]any, len(keys))
+	for i, k := range keys {
+		out[i] = k
+	}
+	return out
+}
+
+func TestValidate_RejectsMalformedEvents(t *testing.T) {
+	cases := map[string]struct {
+		body   string
+		reason string
+	}{
+		"JSON illisible":    {`{"eventType": `, "JSON illisible"},
+		"eventType inconnu": {`{"eventType":"Inconnu","version":"1.0","payload":{}}`, "eventType inconnu"},
+		"champ manquant":    {`{"eventType":"UserDeleted","version":"1.0","timestamp":"2025-04-15T10:00:00Z","payload":{"userID":"u-1"}}`, "deletedAt"},
+		"date invalide":     {`{"eventType":"UserDeleted","version":"1.0","timestamp":"hier","payload":{"userID":"u-1","deletedAt":"2025-04-15T10:00:00Z"}}`, "/timestamp"},
+		"quantité nulle":    {`{"eventType":"OrderCreated","version":"4.0","timestamp":"2025-04-15T10:00:00Z","payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":0,"unitPrice":100}],"totalAmount":1,"currency":"EUR","orderDate":"2025-04-15T10:00:00Z"}}`, "/payload/items/0/quantity"},
+		"montant décimal":   {`{"eventType":"OrderCreated","version":"4.0","timestamp":"2025-04-15T10:00:00Z","payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1,"unitPrice":42.5}],"totalAmount":4250,"currency":"EUR","orderDate":"2025-04-15T10:00:00Z"}}`, "/payload/items/0/unitPrice"},
+		"prix nul":          {`{"eventType":"OrderCreated","version":"4.0","timestamp":"2025-04-15T10:00:00Z","payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1,"unitPrice":0}],"totalAmount":1,"currency":"EUR","orderDate":"2025-04-15T10:00:00Z"}}`, "/payload/items/0/unitPrice"},
+		"total nul":         {`{"eventType":"OrderUpdated","version":"4.0","timestamp":"2025-04-15T10:00:00Z","payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1,"unitPrice":100}],"totalAmount":0,"currency":"EUR","status":"confirmee","updatedAt":"2025-04-15T10:00:00Z"}}`, "/payload/totalAmount"},
+		"email mal formé":   {`{"eventType":"UserCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z","payload":{"userID":"u-1","username":"l","email":"pas-un-email","createdAt":"2025-04-15T10:00:00Z"}}`, "/payload/email"},
+		"eventID mal formé": {`{"eventID":"42","eventType":"UserDeleted","version":"1.0","timestamp":"2025-04-15T10:00:00Z","payload":{"userID":"u-1","deletedAt":"2025-04-15T10:00:00Z"}}`, "/eventID"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Validate([]byte(tc.body))
+
+			require.ErrorIs(t, err, ErrInvalidEvent)
+			assert.Contains(t, err.Error(), tc.reason)
+		})
+	}
+}
+
+func TestNewBaseEvent_HasUniqueEventID(t *testing.T) {
+	first, second := NewBaseEvent(TypeUserCreated), NewBaseEvent(TypeUserCreated)
+
+	assert.NotEmpty(t, first.EventID)
+	assert.NotEqual(t, first.EventID, second.EventID)
+	assert.Equal(t, CurrentVersion(TypeUserCreated), first.Version)
+}
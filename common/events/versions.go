@@ -0,0 +1,249 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+)
+
+// DefaultVersion est la version d'un eventType qui n'a jamais évolué.
+const DefaultVersion = "1.0"
+
+// DefaultCurrency est la devise des commandes émises avant l'ajout du champ
+// currency (OrderCreated 1.0).
+const DefaultCurrency = "EUR"
+
+// ErrUnsupportedVersion signale une version d'événement qu'aucun upcaster ne
+// sait amener à la version courante.
+var ErrUnsupportedVersion = errors.New("unsupported event version")
+
+// Upcaster transforme en place un événement décodé (enveloppe complète) de
+// sa version d'origine vers la version suivante.
+type Upcaster func(event map[string]any) error
+
+type versionKey struct {
+	eventType string
+	version   string
+}
+
+type upcastStep struct {
+	to string
+	fn Upcaster
+}
+
+// Registry associe à chaque eventType sa version courante et les upcasters
+// permettant d'y amener les versions antérieures, indexés par
+// (eventType, version d'origine).
+type Registry struct {
+	current   map[string]string
+	upcasters map[versionKey]upcastStep
+}
+
+// NewRegistry crée un registre vide : tous les eventTypes sont en DefaultVersion.
+func NewRegistry() *Registry {
+	return &Registry{
+		current:   make(map[string]string),
+		upcasters: make(map[versionKey]upcastStep),
+	}
+}
+
+// Register déclare un upcaster de from vers to pour eventType ; to devient
+// la version courante si elle est plus récente que celle connue.
+func (r *Registry) Register(eventType, from, to string, fn Upcaster) {
+	r.upcasters[versionKey{eventType, from}] = upcastStep{to: to, fn: fn}
+	if r.CurrentVersion(eventType) == from {
+		r.current[eventType] = to
+	}
+}
+
+// CurrentVersion retourne la version émise aujourd'hui pour eventType.
+func (r *Registry) CurrentVersion(eventType string) string {
+	if v, ok := r.current[eventType]; ok {
+		return v
+	}
+	return DefaultVersion
+}
+
+// Upcast amène un événement sérialisé à la version courante de son
+// eventType. Un événement déjà courant est retourné tel quel ; une version
+// sans chemin d'upcast retourne ErrUnsupportedVersion, un JSON illisible une
+// *ValidationError.
+func (r *Registry) Upcast(body []byte) ([]byte, error) {
+	var header struct {
+		EventType string `json:"eventType"`
+		Version   string `json:"version"`
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		return nil, &ValidationError{Reason: "JSON illisible : " + err.Error()}
+	}
+	current := r.CurrentVersion(header.EventType)
+	if header.Version == current {
+		return body, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var event map[string]any
+	if err := decoder.Decode(&event); err != nil {
+		return nil, &ValidationError{EventType: header.EventType, Reason: "JSON illisible : " + err.Error()}
+	}
+
+	version := header.Version
+	for steps := 0; version != current; steps++ {
+		step, ok := r.upcasters[versionKey{header.EventType, version}]
+		if !ok || steps > len(r.upcasters) {
+			return nil, fmt.Errorf("%w: %s %s (courante : %s)", ErrUnsupportedVersion, header.EventType, version, current)
+		}
+		if err := step.fn(event); err != nil {
+			return nil, fmt.Errorf("upcast %s %s -> %s : %w", header.EventType, version, step.to, err)
+		}
+		version = step.to
+		event["version"] = version
+	}
+
+	return json.Marshal(event)
+}
+
+// registry est le registre des contrats publiés dans ce package.
+var registry = NewRegistry()
+
+func init() {
+	// OrderCreated 2.0 : ajout de la devise, EUR pour les commandes antérieures.
+	registry.Register(TypeOrderCreated, "1.0", "2.0", func(event map[string]any) error {
+		payload, ok := event["payload"].(map[string]any)
+		if !ok {
+			return errors.New("payload absent")
+		}
+		if _, ok := payload["currency"]; !ok {
+			payload["currency"] = DefaultCurrency
+		}
+		return nil
+	})
+
+	// OrderUpdated 2.0 : ajout du statut, inconnu pour les messages antérieurs.
+	registry.Register(TypeOrderUpdated, "1.0", "2.0", func(event map[string]any) error {
+		payload, ok := event["payload"].(map[string]any)
+		if !ok {
+			return errors.New("payload absent")
+		}
+		if _, ok := payload["status"]; !ok {
+			payload["status"] = ""
+		}
+		return nil
+	})
+
+	// OrderCreated et OrderUpdated 3.0 : prix unitaire des articles.
+	registry.Register(TypeOrderCreated, "2.0", "3.0", addUnitPrices)
+	registry.Register(TypeOrderUpdated, "2.0", "3.0", addUnitPrices)
+
+	// OrderCreated et OrderUpdated 4.0 : montants en unités mineures ;
+	// OrderUpdated reçoit la devise, EUR pour les messages antérieurs.
+	registry.Register(TypeOrderCreated, "3.0", "4.0", toMinorUnits)
+	registry.Register(TypeOrderUpdated, "3.0", "4.0", func(event map[string]any) error {
+		payload, ok := event["payload"].(map[string]any)
+		if !ok {
+			return errors.New("payload absent")
+		}
+		if _, ok := payload["currency"]; !ok {
+			payload["currency"] = DefaultCurrency
+		}
+		return toMinorUnits(event)
+	})
+}
+
+// toMinorUnits convertit les montants décimaux d'une commande (totalAmount
+// et prix unitaires) en unités mineures de sa devise.
+func toMinorUnits(event map[string]any) error {
+	payload, ok := event["payload"].(map[string]any)
+	if !ok {
+		return errors.New("payload absent")
+	}
+	currency, _ := payload["currency"].(string)
+
+	convert := func(holder map[string]any, field string) error {
+		var value float64
+		switch v := holder[field].(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return fmt.Errorf("%s : %w", field, err)
+			}
+			value = f
+		case float64: // complété par un upcaster précédent
+			value = v
+		default:
+			return fmt.Errorf("%s absent", field)
+		}
+		amount, err := money.FromMajor(value, currency)
+		if err != nil {
+			return err
+		}
+		holder[field] = amount
+		return nil
+	}
+
+	if err := convert(payload, "totalAmount"); err != nil {
+		return err
+	}
+	items, _ := payload["items"].([]any)
+	for _, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			return errors.New("article mal formé")
+		}
+		if err := convert(item, "unitPrice"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addUnitPrices complète les articles émis sans prix unitaire. Les commandes
+// antérieures n'avaient qu'un article : son prix se déduit du total. Un
+// événement dont le prix ne peut pas être déduit est rejeté plutôt que
+// complété d'un prix nul, que le contrat n'admet pas.
+func addUnitPrices(event map[string]any) error {
+	payload, ok := event["payload"].(map[string]any)
+	if !ok {
+		return errors.New("payload absent")
+	}
+	items, _ := payload["items"].([]any)
+
+	for _, raw := range items {
+		item, ok := raw.(map[string]any)
+		if !ok {
+			return errors.New("article mal formé")
+		}
+		if _, ok := item["unitPrice"]; ok {
+			continue
+		}
+		if len(items) != 1 {
+			return fmt.Errorf("prix unitaire de %v inconnu : l'événement a %d articles", item["productID"], len(items))
+		}
+		total, okTotal := payload["totalAmount"].(json.Number)
+		quantity, okQty := item["quantity"].(json.Number)
+		if !okTotal || !okQty {
+			return errors.New("prix unitaire impossible à déduire du total")
+		}
+		t, errTotal := total.Float64()
+		q, errQty := quantity.Float64()
+		if errTotal != nil || errQty != nil || q <= 0 {
+			return errors.New("prix unitaire impossible à déduire du total")
+		}
+		item["unitPrice"] = t / q
+	}
+	return nil
+}
+
+// CurrentVersion retourne la version émise aujourd'hui pour eventType.
+func CurrentVersion(eventType string) string {
+	return registry.CurrentVersion(eventType)
+}
+
+// Upcast amène un événement sérialisé à la version courante de son eventType.
+func Upcast(body []byte) ([]byte, error) {
+	return registry.Upcast(body)
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderCreatedV1 = `{"eventType":"OrderCreated","version":"1.0","timestamp":"2025-04-15T10:00:00Z",
+	"payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1}],"totalAmount":19.99,"orderDate":"2025-04-15T10:00:00Z"}}`
+
+func TestUpcast_OrderCreatedV1GetsDefaultCurrency(t *testing.T) {
+	body, err := Upcast([]byte(orderCreatedV1))
+	require.NoError(t, err)
+	require.NoError(t, Validate(body))
+
+	var event OrderCreatedEvent
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "4.0", event.Version)
+	assert.Equal(t, DefaultCurrency, event.Payload.Currency)
+	assert.Equal(t, money.Amount(1999), event.Payload.TotalAmount)
+	assert.Equal(t, []OrderItem{{ProductID: "p-1", Quantity: 1, UnitPrice: 1999}}, event.Payload.Items)
+}
+
+func TestUpcast_OrderUpdatedV1GetsEmptyStatus(t *testing.T) {
+	body, err := Upcast([]byte(`{"eventType":"OrderUpdated","version":"1.0","timestamp":"2025-04-15T10:00:00Z",
+		"payload":{"orderID":"c-1","userID":"u-1","items":[],"totalAmount":10,"orderDate":"2025-04-15T10:00:00Z","updatedAt":"2025-04-15T11:00:00Z"}}`))
+	require.NoError(t, err)
+	require.NoError(t, Validate(body))
+
+	var event OrderUpdatedEvent
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "4.0", event.Version)
+	assert.Empty(t, event.Payload.Status)
+	assert.Equal(t, DefaultCurrency, event.Payload.Currency)
+	assert.Equal(t, money.Amount(1000), event.Payload.TotalAmount)
+}
+
+func TestUpcast_OrderCreatedV2DerivesUnitPrices(t *testing.T) {
+	cases := map[string]struct {
+		items string
+		want  []OrderItem
+	}{
+		"un article":         {`[{"productID":"p-1","quantity":2}]`, []OrderItem{{ProductID: "p-1", Quantity: 2, UnitPrice: 1500}}},
+		"prix déjà présent":  {`[{"productID":"p-1","quantity":2,"unitPrice":10}]`, []OrderItem{{ProductID: "p-1", Quantity: 2, UnitPrice: 1000}}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := Upcast([]byte(`{"eventType":"OrderCreated","version":"2.0","timestamp":"2025-04-15T10:00:00Z",
+				"payload":{"orderID":"c-1","userID":"u-1","items":` + tc.items + `,"totalAmount":30,"currency":"EUR","orderDate":"2025-04-15T10:00:00Z"}}`))
+			require.NoError(t, err)
+			require.NoError(t, Validate(body))
+
+			var event OrderCreatedEvent
+			require.NoError(t, json.Unmarshal(body, &event))
+			assert.Equal(t, "4.0", event.Version)
+			assert.Equal(t, tc.want, event.Payload.Items)
+		})
+	}
+}
+
+func TestUpcast_OrderCreatedV2WithoutDerivablePricesIsRejected(t *testing.T) {
+	_, err := Upcast([]byte(`{"eventType":"OrderCreated","version":"2.0","timestamp":"2025-04-15T10:00:00Z",
+		"payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":1},{"productID":"p-2","quantity":1}],"totalAmount":30,"currency":"EUR","orderDate":"2025-04-15T10:00:00Z"}}`))
+
+	assert.ErrorContains(t, err, "prix unitaire de p-1 inconnu")
+}
+
+func TestUpcast_OrderCreatedV3AmountsUseCurrencyMinorUnits(t *testing.T) {
+	cases := map[string]struct {
+		total     string
+		unitPrice string
+		want      money.Amount
+	}{
+		"EUR": {"0.3", "0.1", 10},
+		"JPY": {"1500", "500", 500},
+		"KWD": {"3.015", "1.005", 1005},
+	}
+
+	for currency, tc := range cases {
+		t.Run(currency, func(t *testing.T) {
+			body, err := Upcast([]byte(`{"eventType":"OrderCreated","version":"3.0","timestamp":"2025-04-15T10:00:00Z",
+				"payload":{"orderID":"c-1","userID":"u-1","items":[{"productID":"p-1","quantity":3,"unitPrice":` + tc.unitPrice + `}],
+				"totalAmount":` + tc.total + `,"currency":"` + currency + `","orderDate":"2025-04-15T10:00:00Z"}}`))
+			require.NoError(t, err)
+			require.NoError(t, Validate(body))
+
+			var event OrderCreatedEvent
+			require.NoError(t, json.Unmarshal(body, &event))
+			assert.Equal(t, tc.want, event.Payload.Items[0].UnitPrice)
+			assert.Equal(t, 3*tc.want, event.Payload.TotalAmount)
+		})
+	}
+}
+
+func TestUpcast_CurrentVersionIsUnchanged(t *testing.T) {
+	body := []byte(`{"eventType":"UserCreated","version":"1.0","payload":{}}`)
+
+	upcasted, err := Upcast(body)
+
+	require.NoError(t, err)
+	assert.Equal(t, body, upcasted)
+}
+
+func TestUpcast_UnknownVersion(t *testing.T) {
+	_, err := Upcast([]byte(`{"eventType":"OrderCreated","version":"0.9","payload":{}}`))
+
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestRegistry_ChainsUpcasters(t *testing.T) {
+	r := NewRegistry()
+	r.Register("Test", "1.0", "2.0", func(e map[string]any) error {
+		e["payload"].(map[string]any)["b"] = "ajouté en 2.0"
+		return nil
+	})
+	r.Register("Test", "2.0", "3.0", func(e map[string]any) error {
+		p := e["payload"].(map[string]any)
+		p["c"], p["a"] = p["a"], nil
+		return nil
+	})
+	assert.Equal(t, "3.0", r.CurrentVersion("Test"))
+
+	body, err := r.Upcast([]byte(`{"eventType":"Test","version":"1.0","payload":{"a":12345678901234567}}`))
+	require.NoError(t, err)
+
+	// les nombres sont conservés sans perte de précision
+	assert.JSONEq(t, `{"eventType":"Test","version":"3.0","payload":{"a":null,"b":"ajouté en 2.0","c":12345678901234567}}`, string(body))
+}
+
+func TestRegistry_UpcasterError(t *testing.T) {
+	r := NewRegistry()
+	r.Register("Test", "1.0", "2.0", func(map[string]any) error { return errors.New("champ manquant") })
+
+	_, err := r.Upcast([]byte(`{"eventType":"Test","version":"1.0","payload":{}}`))
+
+	assert.ErrorContains(t, err, "upcast Test 1.0 -> 2.0 : champ manquant")
+}
@@ -0,0 +1,244 @@
+// Package idempotency rend rejouables les requêtes HTTP non idempotentes
+// (POST) : un client qui renvoie une requête avec le même header
+// Idempotency-Key reçoit la réponse d'origine au lieu de créer un doublon.
+//
+// La clé est enregistrée avec l'empreinte de la requête (méthode, chemin et
+// corps). Réutiliser une clé pour une autre requête est refusé (422) ; une
+// clé dont la première requête est encore en cours est refusée (409). Seules
+// les réponses 2xx sont conservées : après un échec ou une panique, la même
+// clé peut être renvoyée. Une requête en cours verrouille la clé pour
+// LockTimeout : si le processus s'arrête avant de répondre, la clé est
+// reprise après ce délai, et la requête trop lente ne touche plus à la
+// réservation de celle qui l'a reprise. Passé le délai de rétention, la clé
+// est oubliée.
+//
+// Table attendue (PostgreSQL) :
+//
+//	CREATE TABLE IF NOT EXISTS idempotency_keys (
+//	  key TEXT PRIMARY KEY,
+//	  fingerprint TEXT NOT NULL,
+//	  created_at TIMESTAMP NOT NULL,
+//	  locked_until TIMESTAMP,
+//	  status_code INT,
+//	  content_type TEXT,
+//	  body BYTEA,
+//	  completed_at TIMESTAMP
+//	);
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header porte la clé choisie par le client.
+	Header = "Idempotency-Key"
+	// ReplayedHeader signale une réponse rejouée depuis le store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// MaxKeyLength borne la taille d'une clé.
+	MaxKeyLength = 255
+	// DefaultRetention est la durée de conservation d'une clé.
+	DefaultRetention = 24 * time.Hour
+	// PurgeInterval est la période de la purge lancée par StartPurge.
+	PurgeInterval = time.Hour
+	// LockTimeout borne la durée du verrou d'une requête en cours ; il doit
+	// dépasser la durée maximale d'un traitement.
+	LockTimeout = time.Minute
+)
+
+// Record est l'état d'une clé : la réponse n'est renseignée qu'une fois la
+// première requête terminée (Completed). Jusque-là, la clé est verrouillée
+// jusqu'à LockedUntil.
+type Record struct {
+	Fingerprint string
+	CreatedAt   time.Time
+	LockedUntil time.Time
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Store conserve les clés d'idempotence.
+type Store interface {
+	// Claim réserve key jusqu'à lockedUntil pour une requête d'empreinte
+	// fingerprint. Une clé créée avant expiredBefore, ou non terminée et dont
+	// le verrou a expiré à now, est considérée comme libre. Si la clé est
+	// déjà prise, Claim retourne son état et claimed vaut false. La clé
+	// réservée a now pour CreatedAt.
+	Claim(ctx context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (existing *Record, claimed bool, err error)
+	// Complete enregistre la réponse de la requête qui a réservé key à
+	// claimedAt. Si une autre requête a repris la clé entre-temps, sa
+	// réservation est conservée et Complete retourne ErrClaimLost.
+	Complete(ctx context.Context, key string, claimedAt time.Time, statusCode int, contentType string, body []byte) error
+	// Release libère key pour qu'elle puisse être renvoyée, sauf si une autre
+	// requête l'a reprise depuis claimedAt.
+	Release(ctx context.Context, key string, claimedAt time.Time) error
+	// Purge supprime les clés créées avant before.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Middleware applique le header Idempotency-Key aux routes qu'il protège.
+// Une requête sans header est traitée normalement.
+func Middleware(store Store, retention time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > MaxKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "could not read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// La réponse doit être enregistrée même si le client a abandonné.
+		ctx := context.WithoutCancel(c.Request.Context())
+		fingerprint := Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+		// la base conserve les dates à la microseconde : now identifie la
+		// réservation dans Complete et Release
+		now := time.Now().UTC().Truncate(time.Microsecond)
+
+		existing, claimed, err := store.Claim(ctx, key, fingerprint, now, now.Add(LockTimeout), now.Add(-retention))
+		if err != nil {
+			log.Println("[Idempotency] Erreur réservation de la clé :", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "could not check idempotency key"})
+			return
+		}
+		if !claimed {
+			replay(c, existing, fingerprint)
+			return
+		}
+
+		release := func() {
+			if err := store.Release(ctx, key, now); err != nil {
+				log.Println("[Idempotency] Erreur libération de la clé :", err)
+			}
+		}
+		// une panique du handler libère la clé avant de remonter au Recovery
+		defer func() {
+			if r := recover(); r != nil {
+				release()
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status < 200 || status >= 300 {
+			release()
+			return
+		}
+		if err := store.Complete(ctx, key, now, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+			log.Println("[Idempotency] Erreur enregistrement de la réponse :", err)
+		}
+	}
+}
+
+// replay répond à une requête dont la clé est déjà prise.
+func replay(c *gin.Context, existing *Record, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key already used for a different request"})
+	case !existing.Completed:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress"})
+	default:
+		c.Header(ReplayedHeader, "true")
+		c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+		c.Abort()
+	}
+}
+
+// Fingerprint calcule l'empreinte d'une requête. Un corps JSON est compacté
+// pour qu'un simple changement d'espacement ne compte pas comme une autre
+// requête.
+func Fingerprint(method, path string, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RetentionFromEnv retourne la durée de conservation des clés (variable
+// IDEMPOTENCY_RETENTION, DefaultRetention par défaut).
+func RetentionFromEnv() time.Duration {
+	if v := os.Getenv("IDEMPOTENCY_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+		log.Printf("[Idempotency] IDEMPOTENCY_RETENTION invalide (%q), valeur par défaut utilisée", v)
+	}
+	return DefaultRetention
+}
+
+// StartPurge lance RunPurge en arrière-plan, toutes les PurgeInterval,
+// jusqu'à l'annulation du contexte.
+func StartPurge(ctx context.Context, store Store, retention time.Duration) {
+	go RunPurge(ctx, store, retention, PurgeInterval)
+}
+
+// RunPurge supprime toutes les interval les clés plus vieilles que
+// retention, jusqu'à l'annulation du contexte.
+func RunPurge(ctx context.Context, store Store, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := store.Purge(ctx, time.Now().UTC().Add(-retention)); err != nil {
+			log.Println("[Idempotency] Erreur purge :", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// responseRecorder copie le corps de la réponse pour pouvoir la rejouer.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,262 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore est un Store en mémoire pour les tests.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]*Record{}}
+}
+
+func (m *memoryStore) Claim(_ context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (*Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.records[key]; ok && !r.CreatedAt.Before(expiredBefore) && (r.Completed || !r.LockedUntil.Before(now)) {
+		existing := *r
+		return &existing, false, nil
+	}
+	m.records[key] = &Record{Fingerprint: fingerprint, CreatedAt: now, LockedUntil: lockedUntil}
+	return nil, true, nil
+}
+
+func (m *memoryStore) Complete(_ context.Context, key string, claimedAt time.Time, statusCode int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[key]
+	if !ok || r.Completed || !r.CreatedAt.Equal(claimedAt) {
+		return ErrClaimLost
+	}
+	r.Completed, r.StatusCode, r.ContentType, r.Body = true, statusCode, contentType, body
+	return nil
+}
+
+func (m *memoryStore) Release(_ context.Context, key string, claimedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.records[key]; ok && !r.Completed && r.CreatedAt.Equal(claimedAt) {
+		delete(m.records, key)
+	}
+	return nil
+}
+
+func (m *memoryStore) Purge(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for key, r := range m.records {
+		if r.CreatedAt.Before(before) {
+			delete(m.records, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// setupRouter expose POST /orders, qui crée une ressource avec un nouvel ID
+// à chaque appel, derrière le middleware.
+func setupRouter(store Store, status *int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/orders", Middleware(store, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.JSON(*status, gin.H{"id": uuid.New().String()})
+	})
+	return r
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware_ReplaysOriginalResponse(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	r := setupRouter(newMemoryStore(), &status, &calls)
+
+	first := post(r, "key-1", `{"amount": 10}`)
+	second := post(r, "key-1", `{"amount":10}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+	assert.Equal(t, "true", second.Header().Get(ReplayedHeader))
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+}
+
+func TestMiddleware_DifferentBodySameKey(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	r := setupRouter(newMemoryStore(), &status, &calls)
+
+	post(r, "key-1", `{"amount":10}`)
+	w := post(r, "key-1", `{"amount":20}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Now().UTC()
+	_, claimed, err := store.Claim(context.Background(), "key-1", Fingerprint(http.MethodPost, "/orders", []byte(`{}`)), now, now.Add(LockTimeout), now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	status, calls := http.StatusCreated, 0
+	w := post(setupRouter(store, &status, &calls), "key-1", `{}`)
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestMiddleware_FailureReleasesKey(t *testing.T) {
+	status, calls := http.StatusInternalServerError, 0
+	r := setupRouter(newMemoryStore(), &status, &calls)
+
+	post(r, "key-1", `{}`)
+	status = http.StatusCreated
+	w := post(r, "key-1", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(ReplayedHeader))
+}
+
+func TestMiddleware_PanicReleasesKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	panics := true
+	r.POST("/orders", Middleware(newMemoryStore(), time.Hour), func(c *gin.Context) {
+		if panics {
+			panic("handler en échec")
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": uuid.New().String()})
+	})
+
+	first := post(r, "key-1", `{}`)
+	panics = false
+	second := post(r, "key-1", `{}`)
+
+	assert.Equal(t, http.StatusInternalServerError, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Empty(t, second.Header().Get(ReplayedHeader))
+}
+
+func TestMiddleware_StaleLockIsTakenOver(t *testing.T) {
+	store := newMemoryStore()
+	// requête interrompue (processus arrêté) dont le verrou a expiré
+	claimedAt := time.Now().UTC().Add(-2 * LockTimeout)
+	store.records["key-1"] = &Record{
+		Fingerprint: Fingerprint(http.MethodPost, "/orders", []byte(`{}`)),
+		CreatedAt:   claimedAt,
+		LockedUntil: claimedAt.Add(LockTimeout),
+	}
+
+	status, calls := http.StatusCreated, 0
+	w := post(setupRouter(store, &status, &calls), "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestMiddleware_SlowRequestLeavesTakeoverAlone(t *testing.T) {
+	for name, status := range map[string]int{"succès": http.StatusCreated, "échec": http.StatusInternalServerError} {
+		t.Run(name, func(t *testing.T) {
+			store := newMemoryStore()
+			fingerprint := Fingerprint(http.MethodPost, "/orders", []byte(`{}`))
+			takenOver := time.Now().UTC().Add(LockTimeout)
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.POST("/orders", Middleware(store, time.Hour), func(c *gin.Context) {
+				// le verrou a expiré pendant le traitement : une autre
+				// requête a repris la clé
+				store.mu.Lock()
+				store.records["key-1"] = &Record{Fingerprint: fingerprint, CreatedAt: takenOver, LockedUntil: takenOver.Add(LockTimeout)}
+				store.mu.Unlock()
+				c.JSON(status, gin.H{"id": uuid.New().String()})
+			})
+
+			post(r, "key-1", `{}`)
+
+			require.Contains(t, store.records, "key-1")
+			assert.Equal(t, takenOver, store.records["key-1"].CreatedAt)
+			assert.False(t, store.records["key-1"].Completed)
+		})
+	}
+}
+
+func TestMiddleware_ExpiredKeyIsReused(t *testing.T) {
+	store := newMemoryStore()
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	store.records["key-1"] = &Record{Fingerprint: "other", CreatedAt: old, Completed: true, StatusCode: http.StatusCreated}
+
+	status, calls := http.StatusCreated, 0
+	w := post(setupRouter(store, &status, &calls), "key-1", `{}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestMiddleware_WithoutKey(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	r := setupRouter(newMemoryStore(), &status, &calls)
+
+	first := post(r, "", `{}`)
+	second := post(r, "", `{}`)
+
+	assert.Equal(t, 2, calls)
+	assert.NotEqual(t, first.Body.String(), second.Body.String())
+}
+
+func TestMiddleware_KeyTooLong(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	w := post(setupRouter(newMemoryStore(), &status, &calls), strings.Repeat("k", MaxKeyLength+1), `{}`)
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint(http.MethodPost, "/orders", []byte(`{"a":1,"b":2}`))
+
+	assert.Equal(t, base, Fingerprint(http.MethodPost, "/orders", []byte("{\n  \"a\": 1,\n  \"b\": 2\n}")))
+	assert.NotEqual(t, base, Fingerprint(http.MethodPost, "/users", []byte(`{"a":1,"b":2}`)))
+	assert.NotEqual(t, base, Fingerprint(http.MethodPost, "/orders", []byte(`{"a":1,"b":3}`)))
+}
+
+func TestRetentionFromEnv(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"":        DefaultRetention,
+		"2h":      2 * time.Hour,
+		"-1h":     DefaultRetention,
+		"un jour": DefaultRetention,
+	} {
+		t.Setenv("IDEMPOTENCY_RETENTION", value)
+		assert.Equal(t, expected, RetentionFromEnv(), "IDEMPOTENCY_RETENTION=%q", value)
+	}
+}
@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrClaimLost signale une clé reprise par une autre requête après
+// l'expiration du verrou : la réponse n'est pas enregistrée.
+var ErrClaimLost = errors.New("idempotency key claimed by another request")
+
+// SQLStore conserve les clés dans la table idempotency_keys.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// NewSQLStore crée un store sur la connexion db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+// Claim insère la clé, ou reprend une clé expirée ou abandonnée (verrou
+// dépassé). Deux requêtes concurrentes avec la même clé ne peuvent pas la
+// réserver toutes les deux : la seconde lit l'état de la première.
+func (s *SQLStore) Claim(ctx context.Context, key, fingerprint string, now, lockedUntil, expiredBefore time.Time) (*Record, bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, created_at, locked_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, created_at = EXCLUDED.created_at, locked_until = EXCLUDED.locked_until,
+		    status_code = NULL, content_type = NULL, body = NULL, completed_at = NULL
+		WHERE idempotency_keys.created_at < $5
+		   OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.locked_until < $3)
+	`, key, fingerprint, now, lockedUntil, expiredBefore)
+	if err != nil {
+		return nil, false, fmt.Errorf("idempotency key %q : %w", key, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if n == 1 {
+		return nil, true, nil
+	}
+
+	var (
+		r           Record
+		locked      sql.NullTime
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+		completedAt sql.NullTime
+	)
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT fingerprint, created_at, locked_until, status_code, content_type, body, completed_at
+		FROM idempotency_keys WHERE key = $1
+	`, key).Scan(&r.Fingerprint, &r.CreatedAt, &locked, &statusCode, &contentType, &r.Body, &completedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("idempotency key %q : %w", key, err)
+	}
+	r.LockedUntil = locked.Time
+	r.Completed = completedAt.Valid
+	r.StatusCode = int(statusCode.Int64)
+	r.ContentType = contentType.String
+	return &r, false, nil
+}
+
+// Complete enregistre la réponse de la clé, si elle est toujours réservée
+// par la requête qui l'a prise à claimedAt.
+func (s *SQLStore) Complete(ctx context.Context, key string, claimedAt time.Time, statusCode int, contentType string, body []byte) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, body = $3, completed_at = $4, locked_until = NULL
+		WHERE key = $5 AND created_at = $6 AND completed_at IS NULL
+	`, statusCode, contentType, body, time.Now().UTC(), key, claimedAt)
+	if err != nil {
+		return fmt.Errorf("idempotency key %q : %w", key, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("idempotency key %q : %w", key, ErrClaimLost)
+	}
+	return nil
+}
+
+// Release supprime une clé dont la requête n'a pas abouti, si elle est
+// toujours réservée par la requête qui l'a prise à claimedAt.
+func (s *SQLStore) Release(ctx context.Context, key string, claimedAt time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys WHERE key = $1 AND created_at = $2 AND completed_at IS NULL
+	`, key, claimedAt)
+	if err != nil {
+		return fmt.Errorf("idempotency key %q : %w", key, err)
+	}
+	return nil
+}
+
+// Purge supprime les clés créées avant before.
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge idempotency_keys : %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,117 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStore_ClaimNewKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WithArgs("key-1", "fp", now, now.Add(time.Minute), now.Add(-time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	existing, claimed, err := NewSQLStore(db).Claim(context.Background(), "key-1", "fp", now, now.Add(time.Minute), now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	assert.True(t, claimed)
+	assert.Nil(t, existing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStore_ClaimExistingKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT fingerprint, created_at, locked_until, status_code, content_type, body, completed_at FROM idempotency_keys`).
+		WithArgs("key-1").
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "created_at", "locked_until", "status_code", "content_type", "body", "completed_at"}).
+			AddRow("fp", now, nil, 201, "application/json", []byte(`{"id":"1"}`), now))
+
+	existing, claimed, err := NewSQLStore(db).Claim(context.Background(), "key-1", "fp", now, now.Add(time.Minute), now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	assert.False(t, claimed)
+	require.NotNil(t, existing)
+	assert.True(t, existing.Completed)
+	assert.Equal(t, 201, existing.StatusCode)
+	assert.Equal(t, "application/json", existing.ContentType)
+	assert.JSONEq(t, `{"id":"1"}`, string(existing.Body))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStore_ClaimInProgressKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now().UTC()
+	mock.ExpectExec(`INSERT INTO idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT fingerprint`).
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "created_at", "locked_until", "status_code", "content_type", "body", "completed_at"}).
+			AddRow("fp", now, now.Add(time.Minute), nil, nil, nil, nil))
+
+	existing, claimed, err := NewSQLStore(db).Claim(context.Background(), "key-1", "fp", now, now.Add(time.Minute), now.Add(-time.Hour))
+	require.NoError(t, err)
+
+	assert.False(t, claimed)
+	assert.False(t, existing.Completed)
+	assert.Equal(t, now.Add(time.Minute), existing.LockedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStore_CompleteOwnClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	claimedAt := time.Now().UTC()
+	mock.ExpectExec(`UPDATE idempotency_keys SET status_code = \$1, content_type = \$2, body = \$3, completed_at = \$4, locked_until = NULL WHERE key = \$5 AND created_at = \$6 AND completed_at IS NULL`).
+		WithArgs(201, "application/json", []byte(`{}`), sqlmock.AnyArg(), "key-1", claimedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewSQLStore(db).Complete(context.Background(), "key-1", claimedAt, 201, "application/json", []byte(`{}`))
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStore_CompleteAfterTakeover(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE idempotency_keys`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = NewSQLStore(db).Complete(context.Background(), "key-1", time.Now().UTC(), 201, "application/json", []byte(`{}`))
+	assert.ErrorIs(t, err, ErrClaimLost)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStore_ReleaseOwnClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	claimedAt := time.Now().UTC()
+	mock.ExpectExec(`DELETE FROM idempotency_keys WHERE key = \$1 AND created_at = \$2 AND completed_at IS NULL`).
+		WithArgs("key-1", claimedAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, NewSQLStore(db).Release(context.Background(), "key-1", claimedAt))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
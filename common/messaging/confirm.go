@@ -0,0 +1,50 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/streadway/amqp"
+)
+
+// ConfirmChannel publie sur un channel AMQP en mode confirm. Publish ne
+// retourne qu'une fois le message confirmé par le broker : une *PublishError
+// (ErrNacked, ou ErrUnroutable pour un message mandatory qu'aucune queue ne
+// reçoit) s'il ne l'a pas pris en charge, amqp.ErrClosed si le channel se
+// ferme avant la confirmation.
+//
+// Un ConfirmChannel ne doit pas être utilisé par plusieurs goroutines à la fois.
+type ConfirmChannel struct {
+	ch       channel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+}
+
+// NewConfirmChannel passe ch en mode confirm et s'abonne à ses confirmations
+// et à ses retours.
+func NewConfirmChannel(ch *amqp.Channel) (*ConfirmChannel, error) {
+	return newConfirmChannel(ch)
+}
+
+func newConfirmChannel(ch channel) (*ConfirmChannel, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, err
+	}
+	return &ConfirmChannel{
+		ch:       ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// Publish publie msg et attend sa confirmation.
+func (c *ConfirmChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if err := c.ch.Publish(exchange, key, mandatory, immediate, msg); err != nil {
+		return err
+	}
+	return awaitConfirm(context.Background(), c.confirms, c.returns, exchange, key)
+}
+
+// Close ferme le channel.
+func (c *ConfirmChannel) Close() error {
+	return c.ch.Close()
+}
@@ -0,0 +1,57 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openConfirmChannel(t *testing.T, broker *fakeBroker) *ConfirmChannel {
+	conn, err := broker.dial("amqp://test")
+	require.NoError(t, err)
+	ch, err := conn.Channel()
+	require.NoError(t, err)
+	cc, err := newConfirmChannel(ch)
+	require.NoError(t, err)
+	return cc
+}
+
+func TestConfirmChannel_Publish(t *testing.T) {
+	broker := &fakeBroker{}
+	ch := openConfirmChannel(t, broker)
+
+	require.NoError(t, ch.Publish("", "commandes.retry.1", true, false, amqp.Publishing{}))
+	assert.Equal(t, []string{"commandes.retry.1"}, broker.published)
+}
+
+func TestConfirmChannel_UnroutableMessage(t *testing.T) {
+	broker := &fakeBroker{routes: map[string]bool{}}
+	ch := openConfirmChannel(t, broker)
+
+	err := ch.Publish("dlx", "commandes.dead", true, false, amqp.Publishing{})
+
+	var pubErr *PublishError
+	require.ErrorAs(t, err, &pubErr)
+	assert.ErrorIs(t, err, ErrUnroutable)
+	assert.Equal(t, "NO_ROUTE", pubErr.Reason)
+
+	// le retour est consommé : la publication suivante n'en hérite pas
+	broker.routes = nil
+	assert.NoError(t, ch.Publish("dlx", "commandes.dead", true, false, amqp.Publishing{}))
+}
+
+func TestConfirmChannel_NackedMessage(t *testing.T) {
+	broker := &fakeBroker{nack: true}
+	ch := openConfirmChannel(t, broker)
+
+	assert.ErrorIs(t, ch.Publish("", "commandes.retry.1", true, false, amqp.Publishing{}), ErrNacked)
+}
+
+func TestConfirmChannel_ClosedChannel(t *testing.T) {
+	ch := openConfirmChannel(t, &fakeBroker{})
+	require.NoError(t, ch.Close())
+
+	assert.ErrorIs(t, ch.Publish("", "commandes.retry.1", true, false, amqp.Publishing{}), amqp.ErrClosed)
+}
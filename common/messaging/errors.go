@@ -0,0 +1,35 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnroutable indique qu'aucune queue n'est liée à la clé de routage
+	// (basic.return d'un message publié avec mandatory=true).
+	ErrUnroutable = errors.New("messaging: message unroutable")
+
+	// ErrNacked indique que le broker a refusé le message (basic.nack).
+	ErrNacked = errors.New("messaging: message nacked by broker")
+)
+
+// PublishError décrit un message que RabbitMQ n'a pas pris en charge.
+// Err vaut ErrUnroutable ou ErrNacked et peut être testée avec errors.Is.
+type PublishError struct {
+	Exchange   string
+	RoutingKey string
+	Reason     string
+	Err        error
+}
+
+func (e *PublishError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("publication %s/%s : %v (%s)", e.Exchange, e.RoutingKey, e.Err, e.Reason)
+	}
+	return fmt.Sprintf("publication %s/%s : %v", e.Exchange, e.RoutingKey, e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
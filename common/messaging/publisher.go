@@ -0,0 +1,416 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/streadway/amqp"
+)
+
+// DefaultPoolSize est le nombre maximal de channels ouverts par défaut.
+const DefaultPoolSize = 8
+
+// DefaultTimeout borne une publication dont le contexte n'a pas d'échéance.
+const DefaultTimeout = 5 * time.Second
+
+// retryDelay espace les nouvelles tentatives sur un channel ou une connexion fermés.
+const retryDelay = 50 * time.Millisecond
+
+// ErrClosed est retournée par Publish une fois le Publisher fermé.
+var ErrClosed = errors.New("messaging: publisher closed")
+
+// Publisher garde une connexion AMQP unique et un pool de channels. Il se
+// reconnecte automatiquement (backoff exponentiel) si le broker coupe la
+// connexion et peut être utilisé depuis plusieurs goroutines.
+//
+// Les channels sont en mode confirm et les messages publiés avec
+// mandatory=true : Publish ne rend la main qu'une fois le message accepté par
+// RabbitMQ et retourne une *PublishError s'il est refusé ou non routable.
+type Publisher struct {
+	url        string
+	dial       func(url string) (connection, error)
+	timeout    time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	slots chan struct{} // limite le nombre de publications simultanées
+	idle  chan pooled   // channels libres, réutilisables
+	done  chan struct{}
+
+	mu     sync.Mutex
+	conn   connection
+	gen    uint64        // incrémenté à chaque nouvelle connexion
+	ready  chan struct{} // fermé lorsque conn est disponible
+	closed bool
+}
+
+// pooled associe un channel à la connexion (génération) qui l'a ouvert et
+// aux notifications de confirmation et de retour qui lui sont propres.
+type pooled struct {
+	ch       channel
+	gen      uint64
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+}
+
+// PublishOption ajuste une publication.
+type PublishOption func(*publishConfig)
+
+type publishConfig struct {
+	mandatory bool
+}
+
+// Optional autorise la publication d'un message qu'aucune queue ne reçoit
+// (mandatory=false), pour un événement purement informatif.
+func Optional() PublishOption {
+	return func(c *publishConfig) { c.mandatory = false }
+}
+
+// NewPublisher crée un Publisher et lance la connexion en arrière-plan : le
+// service peut démarrer même si RabbitMQ n'est pas encore disponible.
+func NewPublisher(url string, poolSize int) *Publisher {
+	p := newPublisher(url, poolSize, dialAMQP)
+	go p.run()
+	return p
+}
+
+// DeclareTimeout borne l'attente du broker par Open.
+const DeclareTimeout = time.Minute
+
+// Open prépare la messagerie d'un service à son démarrage : il déclare la
+// topologie de topology.json sur le broker d'url, en l'attendant au plus
+// DeclareTimeout, puis crée le Publisher partagé du service (une connexion,
+// un pool de channels, une reconnexion automatique). Le Publisher est à
+// fermer avec Close à l'arrêt du service.
+func Open(ctx context.Context, url string) (*Publisher, error) {
+	topology, err := DefaultTopology()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DeclareTimeout)
+	defer cancel()
+	if err := DeclareTopology(ctx, url, topology); err != nil {
+		return nil, err
+	}
+	return NewPublisher(url, DefaultPoolSize), nil
+}
+
+func newPublisher(url string, poolSize int, dial func(string) (connection, error)) *Publisher {
+	if poolSize <= 0 {
+		poolSize = DefaultPoolSize
+	}
+	return &Publisher{
+		url:        url,
+		dial:       dial,
+		timeout:    DefaultTimeout,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 30 * time.Second,
+		slots:      make(chan struct{}, poolSize),
+		idle:       make(chan pooled, poolSize),
+		done:       make(chan struct{}),
+		ready:      make(chan struct{}),
+	}
+}
+
+// Publish publie un message sur un channel du pool et attend sa confirmation
+// par le broker. Si la connexion est en cours de rétablissement, l'appel
+// attend jusqu'à l'expiration du contexte (DefaultTimeout si le contexte n'a
+// pas d'échéance). La publication est tracée et le contexte de trace ajouté
+// aux headers du message.
+func (p *Publisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing, opts ...PublishOption) (err error) {
+	cfg := publishConfig{mandatory: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	ctx, span := tracing.StartPublish(ctx, exchange, routingKey)
+	defer tracing.End(span, &err)
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	tracing.InjectAMQP(ctx, headers)
+	if len(headers) > 0 {
+		msg.Headers = headers
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrClosed
+	}
+	defer func() { <-p.slots }()
+
+	for {
+		pc, err := p.channel(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = pc.ch.Publish(exchange, routingKey, cfg.mandatory, false, msg)
+		if err == nil {
+			err = p.waitConfirm(ctx, pc, exchange, routingKey)
+			if err == nil || isPublishError(err) {
+				p.release(pc)
+				return err
+			}
+		}
+		pc.ch.Close()
+		if !errors.Is(err, amqp.ErrClosed) {
+			return fmt.Errorf("publication %s/%s : %w", exchange, routingKey, err)
+		}
+
+		// channel ou connexion fermés avant la confirmation : on republie
+		if err := p.pause(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// waitConfirm attend l'ack ou le nack du dernier message publié sur pc.
+func (p *Publisher) waitConfirm(ctx context.Context, pc pooled, exchange, routingKey string) error {
+	return awaitConfirm(ctx, pc.confirms, pc.returns, exchange, routingKey)
+}
+
+// awaitConfirm attend la confirmation du dernier message publié sur un
+// channel en mode confirm. RabbitMQ envoie l'éventuel basic.return avant
+// l'ack : il est donc déjà disponible quand la confirmation arrive.
+func awaitConfirm(ctx context.Context, confirms <-chan amqp.Confirmation, returns <-chan amqp.Return, exchange, routingKey string) error {
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			return amqp.ErrClosed
+		}
+		if !confirm.Ack {
+			return &PublishError{Exchange: exchange, RoutingKey: routingKey, Err: ErrNacked}
+		}
+		select {
+		case ret := <-returns:
+			return &PublishError{Exchange: exchange, RoutingKey: routingKey, Reason: ret.ReplyText, Err: ErrUnroutable}
+		default:
+			return nil
+		}
+	case <-ctx.Done():
+		// confirmation perdue : le channel ne peut plus être réutilisé
+		return ctx.Err()
+	}
+}
+
+func isPublishError(err error) bool {
+	var pubErr *PublishError
+	return errors.As(err, &pubErr)
+}
+
+// PublishEvent sérialise l'événement en JSON et le publie sur l'exchange des
+// événements avec la clé de routage donnée, son eventID en MessageId et les
+// correlation et causation IDs de ctx en headers. Un événement qui ne
+// respecte pas son JSON Schema n'est pas publié.
+func (p *Publisher) PublishEvent(ctx context.Context, routingKey string, event any, opts ...PublishOption) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("sérialisation de l'événement %s : %w", routingKey, err)
+	}
+	if err := events.Validate(body); err != nil {
+		return fmt.Errorf("événement %s : %w", routingKey, err)
+	}
+	var envelope events.Envelope
+	_ = json.Unmarshal(body, &envelope)
+
+	var headers amqp.Table
+	for k, v := range correlation.Headers(ctx) {
+		if headers == nil {
+			headers = amqp.Table{}
+		}
+		headers[k] = v
+	}
+
+	return p.Publish(ctx, events.Exchange, routingKey, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    envelope.EventID,
+		Timestamp:    time.Now().UTC(),
+		Body:         body,
+	}, opts...)
+}
+
+// Close ferme la connexion et arrête les tentatives de reconnexion.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	conn := p.conn
+	p.conn = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// channel retourne un channel libre de la connexion courante, ou en ouvre un.
+func (p *Publisher) channel(ctx context.Context) (pooled, error) {
+	for {
+		p.mu.Lock()
+		conn, gen, ready, closed := p.conn, p.gen, p.ready, p.closed
+		p.mu.Unlock()
+
+		if closed {
+			return pooled{}, ErrClosed
+		}
+		if conn == nil {
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return pooled{}, ctx.Err()
+			case <-p.done:
+				return pooled{}, ErrClosed
+			}
+		}
+
+		select {
+		case pc := <-p.idle:
+			if pc.gen == gen {
+				return pc, nil
+			}
+			pc.ch.Close() // ouvert sur une connexion précédente
+			continue
+		default:
+		}
+
+		pc, err := openChannel(conn, gen)
+		if err != nil {
+			log.Println("[RabbitMQ] Échec ouverture channel :", err)
+			if err := p.pause(ctx); err != nil {
+				return pooled{}, err
+			}
+			continue
+		}
+		return pc, nil
+	}
+}
+
+// openChannel ouvre un channel en mode confirm et s'abonne à ses notifications.
+func openChannel(conn connection, gen uint64) (pooled, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return pooled{}, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return pooled{}, err
+	}
+	return pooled{
+		ch:       ch,
+		gen:      gen,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// pause attend retryDelay avant une nouvelle tentative.
+func (p *Publisher) pause(ctx context.Context) error {
+	select {
+	case <-time.After(retryDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrClosed
+	}
+}
+
+// release remet un channel sain dans le pool, ou le ferme s'il appartient à
+// une connexion qui n'est plus la connexion courante.
+func (p *Publisher) release(pc pooled) {
+	p.mu.Lock()
+	current := p.conn != nil && pc.gen == p.gen
+	p.mu.Unlock()
+
+	if current {
+		select {
+		case p.idle <- pc:
+			return
+		default:
+		}
+	}
+	pc.ch.Close()
+}
+
+// run établit la connexion puis la rétablit à chaque coupure, jusqu'à Close.
+func (p *Publisher) run() {
+	delay := p.minBackoff
+	for {
+		conn, err := p.dial(p.url)
+		if err != nil {
+			log.Printf("[RabbitMQ] Connexion échouée, nouvel essai dans %s : %v", delay, err)
+			select {
+			case <-p.done:
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, p.maxBackoff)
+			continue
+		}
+
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		if !p.setConnection(conn) {
+			conn.Close()
+			return
+		}
+		log.Println("[RabbitMQ] Publisher connecté")
+		delay = p.minBackoff
+
+		reason := <-closed
+		if !p.dropConnection() {
+			return
+		}
+		log.Println("[RabbitMQ] Connexion perdue, reconnexion :", reason)
+	}
+}
+
+func (p *Publisher) setConnection(conn connection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conn = conn
+	p.gen++
+	close(p.ready)
+	return true
+}
+
+func (p *Publisher) dropConnection() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.conn = nil
+	p.ready = make(chan struct{})
+	return true
+}
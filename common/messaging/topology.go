@@ -0,0 +1,262 @@
+package messaging
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// topologyJSON est la définition des exchanges, queues et bindings partagée
+// par tous les services.
+//
+//go:embed topology.json
+var topologyJSON []byte
+
+// Topology décrit les exchanges, queues et bindings attendus sur le broker.
+type Topology struct {
+	Exchanges []Exchange `json:"exchanges"`
+	Queues    []Queue    `json:"queues"`
+}
+
+// Exchange décrit un exchange à déclarer.
+type Exchange struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Durable bool   `json:"durable"`
+}
+
+// Queue décrit une queue, son éventuel dead-letter, ses nouvelles tentatives
+// et ses bindings.
+type Queue struct {
+	Name                 string    `json:"name"`
+	Service              string    `json:"service"` // service consommateur (documentation)
+	Durable              bool      `json:"durable"`
+	DeadLetterExchange   string    `json:"deadLetterExchange,omitempty"`
+	DeadLetterRoutingKey string    `json:"deadLetterRoutingKey,omitempty"`
+	Retry                *Retry    `json:"retry,omitempty"`
+	Bindings             []Binding `json:"bindings"`
+}
+
+// Retry décrit les nouvelles tentatives d'un message dont le traitement a
+// échoué : il attend dans une queue de délai (TTL), puis revient dans sa
+// queue. Le délai double à chaque échec, de InitialDelayMs à MaxDelayMs.
+// Après MaxAttempts tentatives, le message part en dead-letter.
+type Retry struct {
+	MaxAttempts    int   `json:"maxAttempts"`
+	InitialDelayMs int64 `json:"initialDelayMs"`
+	MaxDelayMs     int64 `json:"maxDelayMs,omitempty"` // 0 : sans plafond
+}
+
+// Delays retourne le délai avant chaque nouvelle tentative (MaxAttempts-1
+// délais).
+func (r Retry) Delays() []time.Duration {
+	var delays []time.Duration
+	delay := r.InitialDelayMs
+	for i := 1; i < r.MaxAttempts; i++ {
+		if r.MaxDelayMs > 0 {
+			delay = min(delay, r.MaxDelayMs)
+		}
+		delays = append(delays, time.Duration(delay)*time.Millisecond)
+		delay *= 2
+	}
+	return delays
+}
+
+// RetryQueues retourne, dans l'ordre des tentatives, le nom des queues de
+// délai de la queue. Le nom contient le délai : deux tentatives de même
+// délai partagent leur queue, et changer un délai crée une nouvelle queue
+// plutôt qu'un conflit de x-message-ttl.
+func (q Queue) RetryQueues() []string {
+	if q.Retry == nil {
+		return nil
+	}
+	var names []string
+	for _, d := range q.Retry.Delays() {
+		names = append(names, retryQueueName(q.Name, d))
+	}
+	return names
+}
+
+func retryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queue, delay.Milliseconds())
+}
+
+// Binding lie une queue à un exchange pour une clé de routage.
+type Binding struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routingKey"`
+}
+
+// DefaultTopology retourne la topologie versionnée dans topology.json.
+func DefaultTopology() (Topology, error) {
+	return ParseTopology(topologyJSON)
+}
+
+// ParseTopology décode une définition JSON et vérifie sa cohérence.
+func ParseTopology(data []byte) (Topology, error) {
+	var t Topology
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Topology{}, fmt.Errorf("topologie invalide : %w", err)
+	}
+	if err := t.validate(); err != nil {
+		return Topology{}, fmt.Errorf("topologie invalide : %w", err)
+	}
+	return t, nil
+}
+
+// Queue retourne la définition d'une queue par son nom.
+func (t Topology) Queue(name string) (Queue, bool) {
+	for _, q := range t.Queues {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return Queue{}, false
+}
+
+func (t Topology) validate() error {
+	exchanges := make(map[string]bool)
+	for _, e := range t.Exchanges {
+		if e.Name == "" || e.Type == "" {
+			return errors.New("exchange sans nom ou sans type")
+		}
+		exchanges[e.Name] = true
+	}
+
+	for _, q := range t.Queues {
+		if q.Name == "" {
+			return errors.New("queue sans nom")
+		}
+		if q.DeadLetterExchange != "" && !exchanges[q.DeadLetterExchange] {
+			return fmt.Errorf("queue %q : dead-letter exchange %q non déclaré", q.Name, q.DeadLetterExchange)
+		}
+		if r := q.Retry; r != nil {
+			if r.MaxAttempts < 1 || r.InitialDelayMs <= 0 || r.MaxDelayMs < 0 {
+				return fmt.Errorf("queue %q : retry invalide (maxAttempts >= 1, initialDelayMs > 0)", q.Name)
+			}
+			if q.DeadLetterExchange == "" {
+				return fmt.Errorf("queue %q : retry sans dead-letter exchange", q.Name)
+			}
+		}
+		for _, b := range q.Bindings {
+			if !exchanges[b.Exchange] {
+				return fmt.Errorf("queue %q : exchange %q non déclaré", q.Name, b.Exchange)
+			}
+		}
+	}
+	return nil
+}
+
+// arguments retourne les x-arguments AMQP de la queue.
+func (q Queue) arguments() amqp.Table {
+	if q.DeadLetterExchange == "" {
+		return nil
+	}
+	args := amqp.Table{"x-dead-letter-exchange": q.DeadLetterExchange}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	return args
+}
+
+// declarer est la partie de *amqp.Channel nécessaire à la déclaration.
+type declarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+// Declare déclare la topologie sur la connexion. Les déclarations sont
+// idempotentes ; si un exchange ou une queue existe déjà avec une autre
+// configuration, le broker refuse (PRECONDITION_FAILED) et l'erreur le dit.
+func (t Topology) Declare(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("topologie : ouverture channel : %w", err)
+	}
+	defer ch.Close()
+	return t.declare(ch)
+}
+
+func (t Topology) declare(ch declarer) error {
+	for _, e := range t.Exchanges {
+		if err := ch.ExchangeDeclare(e.Name, e.Type, e.Durable, false, false, false, nil); err != nil {
+			return topologyError("l'exchange", e.Name, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, false, false, false, q.arguments()); err != nil {
+			return topologyError("la queue", q.Name, err)
+		}
+		for _, b := range q.Bindings {
+			if err := ch.QueueBind(q.Name, b.RoutingKey, b.Exchange, false, nil); err != nil {
+				return fmt.Errorf("topologie : binding %s -[%s]-> %s : %w", b.Exchange, b.RoutingKey, q.Name, err)
+			}
+		}
+		if err := declareRetryQueues(ch, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declareRetryQueues déclare les queues de délai de q. Elles ne sont liées à
+// aucun exchange : le consumer y publie via l'exchange par défaut, et un
+// message expiré repart par ce même exchange vers q.
+func declareRetryQueues(ch declarer, q Queue) error {
+	if q.Retry == nil {
+		return nil
+	}
+	for _, d := range q.Retry.Delays() {
+		name := retryQueueName(q.Name, d)
+		args := amqp.Table{
+			"x-message-ttl":             d.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": q.Name,
+		}
+		if _, err := ch.QueueDeclare(name, q.Durable, false, false, false, args); err != nil {
+			return topologyError("la queue", name, err)
+		}
+	}
+	return nil
+}
+
+func topologyError(kind, name string, err error) error {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+		return fmt.Errorf("topologie : %s %q existe déjà sur le broker avec une configuration différente de topology.json : %w", kind, name, err)
+	}
+	return fmt.Errorf("topologie : déclaration de %s %q : %w", kind, name, err)
+}
+
+// DeclareTopology se connecte au broker (en réessayant jusqu'à l'expiration
+// du contexte), déclare la topologie puis ferme la connexion.
+func DeclareTopology(ctx context.Context, url string, t Topology) error {
+	delay := 500 * time.Millisecond
+	for {
+		conn, err := amqp.Dial(url)
+		if err == nil {
+			defer conn.Close()
+			if err := t.Declare(conn); err != nil {
+				return err
+			}
+			log.Printf("[RabbitMQ] Topologie déclarée (%d exchanges, %d queues)", len(t.Exchanges), len(t.Queues))
+			return nil
+		}
+		log.Printf("[RabbitMQ] Connexion échouée, nouvel essai dans %s : %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("topologie : broker injoignable : %w", err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 10*time.Second)
+	}
+}
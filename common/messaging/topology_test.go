@@ -0,0 +1,142 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeclarer enregistre les déclarations et peut refuser une queue.
+type fakeDeclarer struct {
+	exchanges []string
+	queues    map[string]amqp.Table
+	bindings  []string
+	conflict  string
+}
+
+func (f *fakeDeclarer) ExchangeDeclare(name, _ string, _, _, _, _ bool, _ amqp.Table) error {
+	f.exchanges = append(f.exchanges, name)
+	return nil
+}
+
+func (f *fakeDeclarer) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	if name == f.conflict {
+		return amqp.Queue{}, &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'x-dead-letter-exchange'"}
+	}
+	if f.queues == nil {
+		f.queues = make(map[string]amqp.Table)
+	}
+	f.queues[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (f *fakeDeclarer) QueueBind(name, key, exchange string, _ bool, _ amqp.Table) error {
+	f.bindings = append(f.bindings, exchange+"/"+key+"->"+name)
+	return nil
+}
+
+func TestDefaultTopology_IsConsistent(t *testing.T) {
+	topo, err := DefaultTopology()
+	require.NoError(t, err)
+
+	// chaque événement publié en mandatory doit atteindre au moins une queue
+	bound := make(map[string]bool)
+	for _, q := range topo.Queues {
+		for _, b := range q.Bindings {
+			if b.Exchange == events.Exchange {
+				bound[b.RoutingKey] = true
+			}
+		}
+	}
+	assert.True(t, bound[events.RoutingKeyUserCreated])
+	assert.True(t, bound[events.RoutingKeyUserUpdated])
+	assert.True(t, bound[events.RoutingKeyUserDeleted])
+	assert.True(t, bound[events.RoutingKeyOrderCreated])
+	assert.True(t, bound[events.RoutingKeyOrderUpdated])
+	assert.True(t, bound[events.RoutingKeyOrderCanceled])
+
+	q, ok := topo.Queue("notifications")
+	require.True(t, ok)
+	assert.Equal(t, "events.dlx", q.DeadLetterExchange)
+}
+
+func TestParseTopology_RejectsUnknownExchange(t *testing.T) {
+	_, err := ParseTopology([]byte(`{
+		"exchanges": [{"name": "events", "type": "topic", "durable": true}],
+		"queues": [{"name": "q", "durable": true, "bindings": [{"exchange": "inconnu", "routingKey": "x"}]}]
+	}`))
+
+	assert.ErrorContains(t, err, `exchange "inconnu" non déclaré`)
+}
+
+func TestTopologyDeclare(t *testing.T) {
+	topo, err := DefaultTopology()
+	require.NoError(t, err)
+
+	ch := &fakeDeclarer{}
+	require.NoError(t, topo.declare(ch))
+
+	assert.Equal(t, []string{"events", "events.dlx"}, ch.exchanges)
+	assert.Equal(t, amqp.Table{
+		"x-dead-letter-exchange":    "events.dlx",
+		"x-dead-letter-routing-key": "notifications",
+	}, ch.queues["notifications"])
+	assert.Contains(t, ch.bindings, "events/user.created->notifications")
+	assert.Contains(t, ch.bindings, "events.dlx/notifications->notifications.dlq")
+	assert.Contains(t, ch.bindings, "events/user.deleted->commandes.users")
+	assert.Contains(t, ch.bindings, "events.dlx/commandes.users->commandes.users.dlq")
+}
+
+func TestTopologyDeclare_ConflictIsExplicit(t *testing.T) {
+	topo, err := DefaultTopology()
+	require.NoError(t, err)
+
+	err = topo.declare(&fakeDeclarer{conflict: "notifications"})
+
+	assert.ErrorContains(t, err, `la queue "notifications" existe déjà sur le broker avec une configuration différente`)
+	var amqpErr *amqp.Error
+	assert.ErrorAs(t, err, &amqpErr)
+}
+
+func TestRetry_DelaysDoubleUpToMax(t *testing.T) {
+	r := Retry{MaxAttempts: 6, InitialDelayMs: 1000, MaxDelayMs: 5000}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, r.Delays())
+	assert.Empty(t, Retry{MaxAttempts: 1, InitialDelayMs: 1000}.Delays())
+}
+
+func TestTopologyDeclare_RetryQueues(t *testing.T) {
+	topo, err := DefaultTopology()
+	require.NoError(t, err)
+
+	ch := &fakeDeclarer{}
+	require.NoError(t, topo.declare(ch))
+
+	q, ok := topo.Queue("notifications")
+	require.True(t, ok)
+	retryQueues := q.RetryQueues()
+	require.Len(t, retryQueues, q.Retry.MaxAttempts-1)
+	assert.Equal(t, "notifications.retry.1000ms", retryQueues[0])
+	assert.Equal(t, amqp.Table{
+		"x-message-ttl":             int64(1000),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "notifications",
+	}, ch.queues["notifications.retry.1000ms"])
+
+	q, ok = topo.Queue("commandes.users")
+	require.True(t, ok)
+	assert.NotEmpty(t, q.RetryQueues())
+}
+
+func TestParseTopology_RejectsRetryWithoutDeadLetter(t *testing.T) {
+	_, err := ParseTopology([]byte(`{
+		"exchanges": [{"name": "events", "type": "topic", "durable": true}],
+		"queues": [{"name": "q", "durable": true, "retry": {"maxAttempts": 3, "initialDelayMs": 1000}, "bindings": []}]
+	}`))
+
+	assert.ErrorContains(t, err, "retry sans dead-letter exchange")
+}
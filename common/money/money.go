@@ -0,0 +1,92 @@
+// Package money représente les montants sans virgule flottante : un Amount
+// est un nombre entier d'unités mineures (centimes pour EUR) et s'utilise
+// toujours avec un code devise ISO 4217.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Amount est un montant en unités mineures de sa devise.
+type Amount int64
+
+// ErrUnknownCurrency est retournée pour un code qui n'est pas une devise
+// ISO 4217.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// minorUnits donne le nombre de décimales des devises ISO 4217 qui n'en ont
+// pas deux ; toutes les autres devises de currencies en ont deux.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// currencies liste les devises ISO 4217 en circulation (hors métaux et
+// codes de test).
+var currencies = toSet(`
+	AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+	BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU
+	CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP
+	GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES
+	KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD
+	MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD OMR
+	PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD
+	SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS
+	UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XCD XOF XPF YER
+	ZAR ZMW ZWL`)
+
+func toSet(codes string) map[string]bool {
+	set := make(map[string]bool)
+	for _, code := range strings.Fields(codes) {
+		set[code] = true
+	}
+	return set
+}
+
+// IsCurrency indique si code est une devise ISO 4217 acceptée.
+func IsCurrency(code string) bool {
+	return currencies[code]
+}
+
+// MinorUnits retourne le nombre de décimales de la devise.
+func MinorUnits(currency string) (int, error) {
+	if !IsCurrency(currency) {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	if units, ok := minorUnits[currency]; ok {
+		return units, nil
+	}
+	return 2, nil
+}
+
+// FromMajor convertit un montant décimal (ex. 49.99) en unités mineures,
+// arrondi au plus proche. Réservé à la reprise des anciens montants
+// flottants.
+func FromMajor(value float64, currency string) (Amount, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return 0, err
+	}
+	return Amount(math.Round(value * math.Pow10(units))), nil
+}
+
+// Format affiche le montant dans sa devise, ex. « 49.99 EUR ».
+func (a Amount) Format(currency string) string {
+	units, err := MinorUnits(currency)
+	if err != nil || units == 0 {
+		return fmt.Sprintf("%d %s", a, currency)
+	}
+
+	sign, value := "", int64(a)
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	scale := int64(math.Pow10(units))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, value/scale, units, value%scale, currency)
+}
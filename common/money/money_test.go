@@ -0,0 +1,49 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinorUnits(t *testing.T) {
+	cases := map[string]int{"EUR": 2, "USD": 2, "JPY": 0, "KWD": 3, "CLF": 4}
+
+	for currency, want := range cases {
+		units, err := MinorUnits(currency)
+		require.NoError(t, err, currency)
+		assert.Equal(t, want, units, currency)
+	}
+
+	_, err := MinorUnits("XYZ")
+	assert.ErrorIs(t, err, ErrUnknownCurrency)
+	assert.False(t, IsCurrency("eur"))
+}
+
+func TestFromMajor_RoundsToMinorUnits(t *testing.T) {
+	cases := []struct {
+		value    float64
+		currency string
+		want     Amount
+	}{
+		{19.99, "EUR", 1999},
+		{0.1 + 0.2, "EUR", 30},
+		{1.005, "KWD", 1005},
+		{1500, "JPY", 1500},
+	}
+
+	for _, tc := range cases {
+		amount, err := FromMajor(tc.value, tc.currency)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, amount, "%v %s", tc.value, tc.currency)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "49.99 EUR", Amount(4999).Format("EUR"))
+	assert.Equal(t, "0.05 EUR", Amount(5).Format("EUR"))
+	assert.Equal(t, "-1.50 USD", Amount(-150).Format("USD"))
+	assert.Equal(t, "1500 JPY", Amount(1500).Format("JPY"))
+	assert.Equal(t, "1.005 KWD", Amount(1005).Format("KWD"))
+}
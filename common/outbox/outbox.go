@@ -0,0 +1,111 @@
+// Package outbox implémente le pattern « transactional outbox » : un
+// événement est écrit dans la table outbox dans la même transaction SQL que
+// la donnée métier, puis un Relay le publie sur RabbitMQ. Base et événements
+// ne peuvent ainsi plus diverger.
+//
+// Table attendue (PostgreSQL) :
+//
+//	CREATE TABLE IF NOT EXISTS outbox (
+//	  id UUID PRIMARY KEY,
+//	  exchange TEXT NOT NULL,
+//	  routing_key TEXT NOT NULL,
+//	  payload JSONB NOT NULL,
+//	  headers JSONB,
+//	  created_at TIMESTAMP NOT NULL,
+//	  attempts INT NOT NULL DEFAULT 0,
+//	  next_attempt_at TIMESTAMP NOT NULL,
+//	  last_error TEXT,
+//	  sent_at TIMESTAMP
+//	);
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/google/uuid"
+)
+
+// Message est un événement en attente de publication.
+type Message struct {
+	ID         string
+	Exchange   string
+	RoutingKey string
+	Payload    []byte
+	Headers    map[string]string // headers AMQP : corrélation et contexte de trace
+	CreatedAt  time.Time
+	Attempts   int
+}
+
+// NewMessage sérialise un événement destiné à l'exchange des événements.
+// Un événement qui ne respecte pas son JSON Schema est refusé. Le message
+// reprend l'eventID de l'événement, publié comme MessageId AMQP, ainsi que
+// les correlation et causation IDs et le contexte de trace de ctx.
+func NewMessage(ctx context.Context, routingKey string, event any) (Message, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return Message{}, fmt.Errorf("sérialisation de l'événement %s : %w", routingKey, err)
+	}
+	if err := events.Validate(payload); err != nil {
+		return Message{}, fmt.Errorf("événement %s : %w", routingKey, err)
+	}
+
+	var envelope events.Envelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return Message{}, fmt.Errorf("événement %s : %w", routingKey, err)
+	}
+	id := envelope.EventID
+	if id == "" {
+		id = uuid.New().String()
+	}
+	return Message{
+		ID:         id,
+		Exchange:   events.Exchange,
+		RoutingKey: routingKey,
+		Payload:    payload,
+		Headers:    messageHeaders(ctx),
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// messageHeaders retourne les headers à publier avec un message créé dans ctx, ou
+// nil.
+func messageHeaders(ctx context.Context) map[string]string {
+	h := correlation.Headers(ctx)
+	if h == nil {
+		h = map[string]string{}
+	}
+	tracing.Inject(ctx, h)
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+// Enqueue enregistre les messages dans la table outbox au sein de la
+// transaction tx : ils ne seront visibles du Relay qu'après son commit.
+func Enqueue(tx *sql.Tx, messages ...Message) error {
+	for _, m := range messages {
+		var headers []byte
+		if len(m.Headers) > 0 {
+			var err error
+			if headers, err = json.Marshal(m.Headers); err != nil {
+				return fmt.Errorf("outbox %s : %w", m.RoutingKey, err)
+			}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO outbox (id, exchange, routing_key, payload, headers, created_at, next_attempt_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $6)
+		`, m.ID, m.Exchange, m.RoutingKey, m.Payload, headers, m.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("outbox %s : %w", m.RoutingKey, err)
+		}
+	}
+	return nil
+}
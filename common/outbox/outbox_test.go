@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing/tracingtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewMessage_UsesEventID(t *testing.T) {
+	event := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: "u-1"},
+	}
+	event.Payload.DeletedAt = event.Timestamp
+
+	m, err := NewMessage(context.Background(), events.RoutingKeyUserDeleted, event)
+	require.NoError(t, err)
+
+	assert.Equal(t, event.EventID, m.ID)
+	assert.Equal(t, events.Exchange, m.Exchange)
+	assert.Nil(t, m.Headers)
+}
+
+func TestNewMessage_CarriesCorrelation(t *testing.T) {
+	event := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: "u-1"},
+	}
+	event.Payload.DeletedAt = event.Timestamp
+	ctx := correlation.WithIDs(context.Background(), "corr-1", "cause-1")
+
+	m, err := NewMessage(ctx, events.RoutingKeyUserDeleted, event)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		correlation.AMQPCorrelationID: "corr-1",
+		correlation.AMQPCausationID:   "cause-1",
+	}, m.Headers)
+}
+
+func TestNewMessage_CarriesTraceContext(t *testing.T) {
+	tracingtest.Record(t)
+	event := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: "u-1"},
+	}
+	event.Payload.DeletedAt = event.Timestamp
+	ctx, span := tracing.Tracer().Start(context.Background(), "DELETE /users/:id")
+	defer span.End()
+
+	m, err := NewMessage(ctx, events.RoutingKeyUserDeleted, event)
+	require.NoError(t, err)
+
+	restored := trace.SpanContextFromContext(tracing.Extract(context.Background(), m.Headers))
+	assert.Equal(t, span.SpanContext().TraceID(), restored.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), restored.SpanID())
+}
@@ -0,0 +1,179 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/streadway/amqp"
+)
+
+// Publisher est la partie de *messaging.Publisher utilisée par le Relay.
+type Publisher interface {
+	Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing, opts ...messaging.PublishOption) error
+}
+
+// Relay publie périodiquement les messages en attente de la table outbox.
+// Plusieurs instances peuvent tourner en parallèle : chaque lot est verrouillé
+// avec FOR UPDATE SKIP LOCKED.
+type Relay struct {
+	DB        *sql.DB
+	Publisher Publisher
+
+	Interval   time.Duration // délai entre deux passages quand l'outbox est vide
+	BatchSize  int
+	MinBackoff time.Duration // délai avant la première nouvelle tentative
+	MaxBackoff time.Duration
+}
+
+// NewRelay crée un Relay avec les réglages par défaut.
+func NewRelay(db *sql.DB, publisher Publisher) *Relay {
+	return &Relay{
+		DB:         db,
+		Publisher:  publisher,
+		Interval:   time.Second,
+		BatchSize:  50,
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+}
+
+// Run publie les messages en attente jusqu'à l'annulation du contexte.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.RelayBatch(ctx)
+			if err != nil {
+				log.Println("[Outbox] Erreur relais :", err)
+			}
+			if err != nil || n < r.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch publie un lot de messages dus et retourne le nombre de messages
+// traités. Un message publié est marqué envoyé ; un échec est replanifié avec
+// un backoff exponentiel.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, exchange, routing_key, payload, headers, created_at, attempts
+		FROM outbox
+		WHERE sent_at IS NULL AND next_attempt_at <= $1
+		ORDER BY created_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, time.Now().UTC(), r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var batch []Message
+	for rows.Next() {
+		var m Message
+		var headers []byte
+		if err := rows.Scan(&m.ID, &m.Exchange, &m.RoutingKey, &m.Payload, &headers, &m.CreatedAt, &m.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if len(headers) > 0 {
+			if err := json.Unmarshal(headers, &m.Headers); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("outbox %s : headers : %w", m.ID, err)
+			}
+		}
+		batch = append(batch, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range batch {
+		// la publication rejoint la trace de la requête qui a créé le message
+		if err := r.publish(tracing.Extract(ctx, m.Headers), m); err != nil {
+			log.Printf("[Outbox] Échec publication %s (%s, tentative %d) : %v", m.ID, m.RoutingKey, m.Attempts+1, err)
+			if err := r.markFailed(ctx, tx, m, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := r.markSent(ctx, tx, m); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+func (r *Relay) publish(ctx context.Context, m Message) error {
+	var headers amqp.Table
+	if len(m.Headers) > 0 {
+		headers = amqp.Table{}
+		for k, v := range m.Headers {
+			headers[k] = v
+		}
+	}
+	return r.Publisher.Publish(ctx, m.Exchange, m.RoutingKey, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.ID,
+		Timestamp:    m.CreatedAt,
+		Body:         m.Payload,
+	})
+}
+
+func (r *Relay) markSent(ctx context.Context, tx *sql.Tx, m Message) error {
+	_, err := tx.ExecContext(ctx, `UPDATE outbox SET sent_at = $1, attempts = attempts + 1 WHERE id = $2`,
+		time.Now().UTC(), m.ID)
+	if err != nil {
+		return fmt.Errorf("outbox %s : %w", m.ID, err)
+	}
+	return nil
+}
+
+func (r *Relay) markFailed(ctx context.Context, tx *sql.Tx, m Message, cause error) error {
+	next := time.Now().UTC().Add(r.backoff(m.Attempts))
+	_, err := tx.ExecContext(ctx, `
+		UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2
+		WHERE id = $3
+	`, next, cause.Error(), m.ID)
+	if err != nil {
+		return fmt.Errorf("outbox %s : %w", m.ID, err)
+	}
+	return nil
+}
+
+// backoff retourne le délai avant la tentative suivant attempts échecs.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.MinBackoff
+	for i := 0; i < attempts && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.MaxBackoff)
+}
@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakePublisher enregistre les messages publiés et échoue sur demande.
+type fakePublisher struct {
+	published []amqp.Publishing
+	traces    []trace.SpanContext // contexte de trace de chaque publication
+	failOn    map[string]error
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, _, routingKey string, msg amqp.Publishing, _ ...messaging.PublishOption) error {
+	if err := f.failOn[routingKey]; err != nil {
+		return err
+	}
+	f.published = append(f.published, msg)
+	f.traces = append(f.traces, trace.SpanContextFromContext(ctx))
+	return nil
+}
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func outboxRows(now time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "exchange", "routing_key", "payload", "headers", "created_at", "attempts"}).
+		AddRow("11111111-1111-1111-1111-111111111111", "events", "commande.created", []byte(`{"eventType":"OrderCreated"}`),
+			[]byte(`{"x-correlation-id":"corr-1","x-causation-id":"corr-1","traceparent":"`+traceparent+`"}`), now, 0).
+		AddRow("22222222-2222-2222-2222-222222222222", "events", "user.created", []byte(`{"eventType":"UserCreated"}`), nil, now, 3)
+}
+
+func TestRelayBatch_PublishesAndMarksSent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, exchange, routing_key, payload, headers, created_at, attempts FROM outbox`).
+		WillReturnRows(outboxRows(time.Now().UTC()))
+	mock.ExpectExec(`UPDATE outbox SET sent_at`).
+		WithArgs(sqlmock.AnyArg(), "11111111-1111-1111-1111-111111111111").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox SET sent_at`).
+		WithArgs(sqlmock.AnyArg(), "22222222-2222-2222-2222-222222222222").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	publisher := &fakePublisher{}
+	relay := NewRelay(db, publisher)
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, n)
+	require.Len(t, publisher.published, 2)
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", publisher.published[0].MessageId)
+	assert.JSONEq(t, `{"eventType":"OrderCreated"}`, string(publisher.published[0].Body))
+	assert.Equal(t, amqp.Table{"x-correlation-id": "corr-1", "x-causation-id": "corr-1", "traceparent": traceparent},
+		publisher.published[0].Headers)
+	assert.Nil(t, publisher.published[1].Headers)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", publisher.traces[0].TraceID().String())
+	assert.False(t, publisher.traces[1].IsValid())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelayBatch_FailureIsRescheduledWithBackoff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	unroutable := &messaging.PublishError{Exchange: "events", RoutingKey: "user.created", Err: messaging.ErrUnroutable}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, exchange, routing_key, payload, headers, created_at, attempts FROM outbox`).
+		WillReturnRows(outboxRows(time.Now().UTC()))
+	mock.ExpectExec(`UPDATE outbox SET sent_at`).
+		WithArgs(sqlmock.AnyArg(), "11111111-1111-1111-1111-111111111111").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox SET attempts = attempts \+ 1, next_attempt_at`).
+		WithArgs(sqlmock.AnyArg(), unroutable.Error(), "22222222-2222-2222-2222-222222222222").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	publisher := &fakePublisher{failOn: map[string]error{"user.created": unroutable}}
+	relay := NewRelay(db, publisher)
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, n)
+	assert.Len(t, publisher.published, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(nil, nil)
+
+	assert.Equal(t, time.Second, relay.backoff(0))
+	assert.Equal(t, 8*time.Second, relay.backoff(3))
+	assert.Equal(t, 5*time.Minute, relay.backoff(20))
+}
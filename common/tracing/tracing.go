@@ -0,0 +1,183 @@
+// Package tracing configure OpenTelemetry pour les services et fournit les
+// spans communs : routes Gin, requêtes des repositories, publication et
+// consommation AMQP. Le contexte de trace W3C (traceparent, tracestate,
+// baggage) voyage dans les headers HTTP et AMQP.
+//
+// L'export OTLP/HTTP se configure par les variables standard
+// d'OpenTelemetry :
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT         ex. http://otel-collector:4318
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  prioritaire pour les traces
+//	OTEL_EXPORTER_OTLP_HEADERS          ex. authorization=...
+//	OTEL_SERVICE_NAME                   remplace le nom passé à Init
+//	OTEL_TRACES_SAMPLER                 ex. parentbased_traceidratio
+//	OTEL_TRACES_EXPORTER                none : désactive l'export
+//
+// Sans endpoint, le TracerProvider reste no-op : les spans ne coûtent rien,
+// mais le contexte de trace reçu est tout de même propagé.
+package tracing
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName identifie l'instrumentation du projet dans les spans.
+const TracerName = "github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+
+// EventTypeKey est l'attribut portant l'eventType d'un message consommé.
+const EventTypeKey = attribute.Key("event.type")
+
+// propagator lit et écrit le contexte de trace W3C et le baggage.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init installe le TracerProvider global du service et retourne la fonction
+// qui vide puis arrête l'export, à appeler avant la sortie du programme.
+func Init(ctx context.Context, service string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagator)
+
+	if !exportEnabled() {
+		log.Println("[Tracing] Aucun endpoint OTLP configuré, traces désactivées")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(service)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	log.Printf("[Tracing] Export OTLP des traces de %s activé", service)
+	return provider.Shutdown, nil
+}
+
+// exportEnabled indique si l'environnement configure un export OTLP.
+func exportEnabled() bool {
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Tracer retourne le tracer du projet, issu du TracerProvider global courant.
+func Tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(TracerName)
+}
+
+// End termine span en y enregistrant *err s'il est non nil. À appeler en
+// defer avec l'adresse de l'erreur retournée par la fonction.
+func End(span trace.Span, err *error) {
+	if err != nil {
+		SetError(span, *err)
+	}
+	span.End()
+}
+
+// SetError marque span en erreur si err est non nil.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// StartDB démarre le span d'une opération d'un repository PostgreSQL.
+func StartDB(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)),
+	)
+}
+
+// Inject écrit le contexte de trace de ctx dans headers.
+func Inject(ctx context.Context, headers map[string]string) {
+	propagator.Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract retourne ctx complété du contexte de trace lu dans headers.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// InjectAMQP écrit le contexte de trace de ctx dans les headers d'un message.
+func InjectAMQP(ctx context.Context, headers amqp.Table) {
+	propagator.Inject(ctx, amqpCarrier(headers))
+}
+
+// ExtractAMQP retourne ctx complété du contexte de trace d'un message reçu.
+func ExtractAMQP(ctx context.Context, headers amqp.Table) context.Context {
+	return propagator.Extract(ctx, amqpCarrier(headers))
+}
+
+// amqpCarrier adapte les headers AMQP à l'interface des propagateurs.
+type amqpCarrier amqp.Table
+
+func (c amqpCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c amqpCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c amqpCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// StartPublish démarre le span de publication d'un message.
+func StartPublish(ctx context.Context, exchange, routingKey string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, "publish "+routingKey,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(routingKey),
+		),
+	)
+}
+
+// StartConsume démarre le span de traitement d'un message reçu sur queue,
+// enfant du contexte de trace de ses headers.
+func StartConsume(ctx context.Context, queue string, d amqp.Delivery) (context.Context, trace.Span) {
+	ctx = ExtractAMQP(ctx, d.Headers)
+	return Tracer().Start(ctx, "process "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitmq,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(queue),
+			semconv.MessagingRabbitmqDestinationRoutingKey(d.RoutingKey),
+			semconv.MessagingMessageID(d.MessageId),
+		),
+	)
+}
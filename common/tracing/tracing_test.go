@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing/tracingtest"
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func serve(path, route string, status int, header http.Header) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(correlation.Middleware(), Middleware())
+	r.GET(route, func(c *gin.Context) { c.Status(status) })
+
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddleware_SpanPerRoute(t *testing.T) {
+	exporter := tracingtest.Record(t)
+
+	w := serve("/commandes/42", "/commandes/:id", http.StatusOK, http.Header{
+		"Traceparent":                   {traceparent},
+		correlation.HeaderCorrelationID: {"corr-1"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	span := tracingtest.Named(t, exporter, "GET /commandes/:id")
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Equal(t, int64(200), attr(span.Attributes, "http.response.status_code").AsInt64())
+	assert.Equal(t, "corr-1", attr(span.Attributes, CorrelationIDKey).AsString())
+	assert.Equal(t, codes.Unset, span.Status.Code)
+}
+
+func TestMiddleware_ServerErrorMarksSpan(t *testing.T) {
+	exporter := tracingtest.Record(t)
+
+	serve("/users", "/users", http.StatusInternalServerError, nil)
+
+	span := tracingtest.Named(t, exporter, "GET /users")
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.False(t, span.Parent.IsValid())
+}
+
+func TestAMQPPropagation(t *testing.T) {
+	exporter := tracingtest.Record(t)
+
+	ctx, span := StartPublish(context.Background(), "events", "user.created")
+	headers := amqp.Table{}
+	InjectAMQP(ctx, headers)
+	span.End()
+	require.Contains(t, headers, "traceparent")
+
+	_, consume := StartConsume(context.Background(), "notifications", amqp.Delivery{Headers: headers, RoutingKey: "user.created"})
+	consume.End()
+
+	publish := tracingtest.Named(t, exporter, "publish user.created")
+	process := tracingtest.Named(t, exporter, "process notifications")
+	assert.Equal(t, trace.SpanKindProducer, publish.SpanKind)
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind)
+	assert.Equal(t, publish.SpanContext.TraceID(), process.SpanContext.TraceID())
+	assert.Equal(t, publish.SpanContext.SpanID(), process.Parent.SpanID())
+}
+
+func TestMapPropagation(t *testing.T) {
+	tracingtest.Record(t)
+
+	ctx, span := StartDB(context.Background(), "InsertCommande")
+	defer span.End()
+	headers := map[string]string{}
+	Inject(ctx, headers)
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), headers))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	exporter := tracingtest.Record(t)
+
+	func() (err error) {
+		_, span := StartDB(context.Background(), "GetCommandeByID")
+		defer End(span, &err)
+		return errors.New("connexion perdue")
+	}()
+
+	span := tracingtest.Named(t, exporter, "GetCommandeByID")
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "connexion perdue", span.Status.Description)
+	assert.Equal(t, "postgresql", attr(span.Attributes, "db.system").AsString())
+}
+
+func TestInit_NoopWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	shutdown, err := Init(context.Background(), "service-test")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, span := Tracer().Start(context.Background(), "ignoré")
+	assert.False(t, span.IsRecording())
+}
@@ -0,0 +1,40 @@
+// Package tracingtest enregistre en mémoire les spans produits pendant un
+// test.
+package tracingtest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Record installe un TracerProvider global qui exporte chaque span terminé
+// vers l'exporter retourné ; le provider no-op est rétabli à la fin du
+// test. Les tests qui l'utilisent ne doivent pas tourner en parallèle.
+func Record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return exporter
+}
+
+// Named retourne le premier span terminé de ce nom.
+func Named(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("aucun span %q parmi %d", name, len(exporter.GetSpans()))
+	return tracetest.SpanStub{}
+}
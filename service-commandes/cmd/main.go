@@ -1,11 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/idempotency"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/business"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/repository"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/server"
 )
@@ -17,17 +27,65 @@ func main() {
 		log.Println("Aucun fichier .env trouvé, on continue avec les variables système...")
 	}
 
+	shutdownTracing, err := tracing.Init(context.Background(), "service-commandes")
+	if err != nil {
+		log.Fatalf("Échec d'initialisation du tracing : %v", err)
+	}
+
 	if err := repository.InitDB(); err != nil {
 		log.Fatalf("Échec d'initialisation de la base de données : %v", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	publisher, err := messaging.Open(ctx, os.Getenv("RABBITMQ_URL"))
+	if err != nil {
+		log.Fatalf("Échec de déclaration de la topologie RabbitMQ : %v", err)
+	}
+
+	// Le relais tourne jusqu'à la fin des requêtes en cours puis s'arrête
+	// avant la fermeture du publisher ; les messages restés dans l'outbox
+	// partiront au prochain démarrage.
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := business.StartOutboxRelay(relayCtx, publisher)
+	idempotency.StartPurge(ctx, business.IdempotencyStore(), idempotency.RetentionFromEnv())
+
+	if err := business.StartUserReplication(ctx); err != nil {
+		log.Fatalf("Échec de démarrage de la réplication des utilisateurs : %v", err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8082" // fallback pour le service commandes
 	}
 
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: server.SetupRouter(),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Échec du serveur HTTP : %v", err)
+			stop()
+		}
+	}()
+
 	fmt.Println("Démarrage du service commandes sur le port", port)
 
-	router := server.SetupRouter()
-	router.Run(":" + port)
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Arrêt du serveur HTTP : %v", err)
+	}
+	stopRelay()
+	<-relayDone
+	if err := publisher.Close(); err != nil {
+		log.Printf("Fermeture de la connexion RabbitMQ : %v", err)
+	}
+	_ = shutdownTracing(shutdownCtx)
+
+	log.Println("Arrêt du service commandes")
 }
@@ -1,8 +1,91 @@
 CREATE TABLE IF NOT EXISTS commandes (
   id UUID PRIMARY KEY,
   user_id UUID NOT NULL,
-  product TEXT NOT NULL,
-  amount FLOAT NOT NULL,
+  amount BIGINT NOT NULL CHECK (amount > 0),
+  currency CHAR(3) NOT NULL,
   status TEXT NOT NULL,
-  created_at TIMESTAMP NOT NULL
-);
\ No newline at end of file
+  created_at TIMESTAMP NOT NULL,
+  cancel_reason TEXT,
+  canceled_by TEXT,
+  canceled_at TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+-- Index de GET /commandes : un par tri, et par filtre fréquent suivi du tri
+-- par défaut. Le montant ne se filtre et ne se trie qu'au sein d'une devise.
+-- L'id départage les égalités de la pagination par curseur.
+CREATE INDEX IF NOT EXISTS commandes_created_idx ON commandes (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_amount_idx ON commandes (currency, amount, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_user_created_idx ON commandes (user_id, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_status_created_idx ON commandes (status, created_at, id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS order_items (
+  commande_id UUID NOT NULL REFERENCES commandes (id) ON DELETE CASCADE,
+  line INT NOT NULL,
+  product_id TEXT NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  unit_price BIGINT NOT NULL CHECK (unit_price > 0),
+  PRIMARY KEY (commande_id, line)
+);
+
+CREATE TABLE IF NOT EXISTS commande_status_history (
+  id BIGSERIAL PRIMARY KEY,
+  commande_id UUID NOT NULL REFERENCES commandes (id) ON DELETE CASCADE,
+  from_status TEXT,
+  to_status TEXT NOT NULL,
+  changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS commande_status_history_commande_idx ON commande_status_history (commande_id, changed_at);
+
+-- Copie locale des utilisateurs, alimentée par la queue commandes.users :
+-- une commande n'est acceptée que pour un utilisateur connu et non supprimé.
+CREATE TABLE IF NOT EXISTS users_replica (
+  user_id UUID PRIMARY KEY,
+  username TEXT NOT NULL,
+  email TEXT NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id UUID PRIMARY KEY,
+  exchange TEXT NOT NULL,
+  routing_key TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  headers JSONB,
+  created_at TIMESTAMP NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_error TEXT,
+  sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
+
+-- Clés Idempotency-Key des POST : empreinte de la requête et réponse
+-- d'origine, rejouée tant que la clé n'a pas expiré. Une requête en cours
+-- verrouille la clé jusqu'à locked_until.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  key TEXT PRIMARY KEY,
+  fingerprint TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP,
+  status_code INT,
+  content_type TEXT,
+  body BYTEA,
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_idx ON idempotency_keys (created_at);
+
+-- Événements déjà traités par chaque queue consommée : un message redélivré
+-- par RabbitMQ n'est traité qu'une fois.
+CREATE TABLE IF NOT EXISTS processed_messages (
+  consumer_group TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  processed_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (consumer_group, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS processed_messages_processed_idx ON processed_messages (processed_at);
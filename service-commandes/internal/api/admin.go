@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader porte le jeton des routes d'administration.
+const AdminTokenHeader = "X-Admin-Token"
+
+// Auteurs d'une action, tels que le service peut les vérifier : il n'a pas
+// d'authentification des clients, seulement le jeton d'administration.
+const (
+	ActorAdmin  = "admin"
+	ActorClient = "client"
+)
+
+// actorKey est la clé du contexte Gin portant l'auteur de la requête.
+const actorKey = "actor"
+
+// AdminOnly réserve une route aux détenteurs du jeton d'administration
+// (variable ADMIN_TOKEN). Sans jeton configuré, la route est fermée.
+func AdminOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c, token) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IdentifyActor enregistre l'auteur de la requête : ActorAdmin si elle
+// porte le jeton d'administration, ActorClient sinon.
+func IdentifyActor(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := ActorClient
+		if isAdmin(c, token) {
+			actor = ActorAdmin
+		}
+		c.Set(actorKey, actor)
+		c.Next()
+	}
+}
+
+// actor retourne l'auteur enregistré par IdentifyActor, ActorClient à défaut.
+func actor(c *gin.Context) string {
+	if a := c.GetString(actorKey); a != "" {
+		return a
+	}
+	return ActorClient
+}
+
+func isAdmin(c *gin.Context, token string) bool {
+	provided := c.GetHeader(AdminTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -1,28 +1,113 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/business"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
 )
 
-// CreateCommandeInput représente les données pour créer une commande
+// OrderItemInput représente une ligne de commande ; le prix unitaire est en
+// unités mineures de la devise (centimes pour EUR) et le montant total est
+// calculé par le service.
+type OrderItemInput struct {
+	ProductID string       `json:"product_id" binding:"required"`
+	Quantity  int          `json:"quantity" binding:"required,gt=0"`
+	UnitPrice money.Amount `json:"unit_price" binding:"required,gt=0"`
+}
+
+// CreateCommandeInput représente les données pour créer une commande ; la
+// devise ISO 4217 vaut EUR si elle est omise.
 type CreateCommandeInput struct {
-	UserID  string  `json:"user_id" binding:"required,uuid"`
-	Product string  `json:"product" binding:"required"`
-	Amount  float64 `json:"amount" binding:"required"`
+	UserID   string           `json:"user_id" binding:"required,uuid"`
+	Currency string           `json:"currency"`
+	Items    []OrderItemInput `json:"items" binding:"required,min=1,dive"`
 }
 
-// UpdateCommandeInput représente les données pour mettre à jour une commande
+// UpdateCommandeInput représente les données pour mettre à jour une commande.
+// Le statut est facultatif ; s'il change, la transition doit être autorisée.
 type UpdateCommandeInput struct {
-	Product string  `json:"product" binding:"required"`
-	Amount  float64 `json:"amount" binding:"required"`
-	Status  string  `json:"status" binding:"required"`
+	Items  []OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Status models.Status    `json:"status" binding:"omitempty,oneof=en_attente confirmee expediee livree annulee remboursee"`
+}
+
+// toOrderItems convertit les lignes reçues en lignes du modèle.
+func toOrderItems(inputs []OrderItemInput) []models.OrderItem {
+	items := make([]models.OrderItem, len(inputs))
+	for i, in := range inputs {
+		items[i] = models.OrderItem{ProductID: in.ProductID, Quantity: in.Quantity, UnitPrice: in.UnitPrice}
+	}
+	return items
+}
+
+// CancelCommandeInput représente les données pour annuler une commande.
+// L'auteur de l'annulation n'en fait pas partie : il est déterminé côté
+// serveur (IdentifyActor).
+type CancelCommandeInput struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ListCommandesQuery représente les paramètres de GET /commandes : filtres
+// facultatifs, tri (« -created_at » par défaut, « - » pour décroissant),
+// taille de page et curseur de la page précédente. Les filtres et le tri sur
+// le montant exigent la devise.
+type ListCommandesQuery struct {
+	UserID      string        `form:"user_id" binding:"omitempty,uuid"`
+	Status      models.Status `form:"status" binding:"omitempty,oneof=en_attente confirmee expediee livree annulee remboursee"`
+	Currency    string        `form:"currency" binding:"omitempty,len=3"`
+	CreatedFrom *time.Time    `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time    `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	MinAmount   *money.Amount `form:"min_amount" binding:"omitempty,gte=0"`
+	MaxAmount   *money.Amount `form:"max_amount" binding:"omitempty,gte=0"`
+	Sort        string        `form:"sort" binding:"omitempty,oneof=created_at -created_at amount -amount"`
+	Limit       int           `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor      string        `form:"cursor"`
+}
+
+// toListQuery convertit les paramètres reçus ; le curseur doit avoir été
+// émis pour le même tri (models.ErrInvalidCursor) et le montant n'est
+// utilisable qu'avec une devise (models.ErrCurrencyRequired).
+func (in ListCommandesQuery) toListQuery() (models.ListQuery, error) {
+	sort := in.Sort
+	if sort == "" {
+		sort = "-created_at"
+	}
+	desc := strings.HasPrefix(sort, "-")
+
+	q := models.ListQuery{
+		UserID:      in.UserID,
+		Status:      in.Status,
+		Currency:    in.Currency,
+		CreatedFrom: in.CreatedFrom,
+		CreatedTo:   in.CreatedTo,
+		MinAmount:   in.MinAmount,
+		MaxAmount:   in.MaxAmount,
+		Sort:        models.SortField(strings.TrimPrefix(sort, "-")),
+		Desc:        desc,
+		Limit:       in.Limit,
+	}
+	if q.Currency != "" && !money.IsCurrency(q.Currency) {
+		return q, fmt.Errorf("%w: %q", money.ErrUnknownCurrency, q.Currency)
+	}
+	if q.UsesAmount() && q.Currency == "" {
+		return q, models.ErrCurrencyRequired
+	}
+	if in.Cursor != "" {
+		cursor, err := models.DecodeCursor(in.Cursor, q.Sort, q.Desc)
+		if err != nil {
+			return q, err
+		}
+		q.Cursor = cursor
+	}
+	return q, nil
 }
 
 // Handler structure injectée avec un service
@@ -51,31 +136,102 @@ func (h *Handler) CreateCommandeHandler(c *gin.Context) {
 	commande := models.Commande{
 		ID:        uuid.New().String(),
 		UserID:    input.UserID,
-		Product:   input.Product,
-		Amount:    input.Amount,
-		Status:    "en_attente",
+		Items:     toOrderItems(input.Items),
+		Currency:  input.Currency,
+		Status:    models.StatusEnAttente,
 		CreatedAt: time.Now().UTC(),
 	}
 
-	if err := h.CommandeService.CreateCommande(c, commande); err != nil {
+	created, err := h.CommandeService.CreateCommande(c, commande)
+	if errors.Is(err, business.ErrInvalidAmount) || errors.Is(err, business.ErrUnknownCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, business.ErrUnknownUser) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		log.Println("[Handler] Erreur création commande :", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create commande"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, commande)
+	c.JSON(http.StatusCreated, created)
 }
 
-// GetAllCommandesHandler traite GET /commandes
+// GetAllCommandesHandler traite GET /commandes (paginé, voir ListCommandesQuery)
 func (h *Handler) GetAllCommandesHandler(c *gin.Context) {
-	commandes, err := h.CommandeService.GetAllCommandes(c)
+	query, ok := bindListQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.CommandeService.GetAllCommandes(c, query)
 	if err != nil {
 		log.Println("[Handler] Erreur récupération commandes :", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch commandes"})
 		return
 	}
 
-	c.JSON(http.StatusOK, commandes)
+	c.JSON(http.StatusOK, page)
+}
+
+// GetUserCommandesHandler traite GET /users/:id/commandes (mêmes paramètres
+// que GET /commandes, user_id excepté)
+func (h *Handler) GetUserCommandesHandler(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+	query, ok := bindListQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.CommandeService.GetUserCommandes(c, userID, query)
+	if err != nil {
+		log.Println("[Handler] Erreur récupération commandes utilisateur :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch commandes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetUserSummaryHandler traite GET /users/:id/commandes/summary
+func (h *Handler) GetUserSummaryHandler(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	summary, err := h.CommandeService.GetUserSummary(c, userID)
+	if err != nil {
+		log.Println("[Handler] Erreur résumé commandes utilisateur :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch commandes summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// bindListQuery lit les paramètres de pagination et répond 400 s'ils sont
+// invalides.
+func bindListQuery(c *gin.Context) (models.ListQuery, bool) {
+	var input ListCommandesQuery
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.ListQuery{}, false
+	}
+	query, err := input.toListQuery()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.ListQuery{}, false
+	}
+	return query, true
 }
 
 // GetCommandeByIDHandler traite GET /commandes/:id
@@ -87,10 +243,15 @@ func (h *Handler) GetCommandeByIDHandler(c *gin.Context) {
 	}
 
 	commande, err := h.CommandeService.GetCommandeByID(c, id)
-	if err != nil {
+	if errors.Is(err, business.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
 		return
 	}
+	if err != nil {
+		log.Println("[Handler] Erreur récupération commande :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch commande"})
+		return
+	}
 
 	c.JSON(http.StatusOK, commande)
 }
@@ -109,15 +270,29 @@ func (h *Handler) UpdateCommandeHandler(c *gin.Context) {
 		return
 	}
 
-	updated := models.Commande{
-		ID:        id,
-		Product:   input.Product,
-		Amount:    input.Amount,
-		Status:    input.Status,
-		CreatedAt: time.Now().UTC(),
+	update := models.Commande{
+		Items:  toOrderItems(input.Items),
+		Status: input.Status,
 	}
 
-	if err := h.CommandeService.UpdateCommande(c, id, updated); err != nil {
+	updated, err := h.CommandeService.UpdateCommande(c, id, update)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
+		return
+	}
+	if errors.Is(err, business.ErrReasonRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use POST /commandes/:id/cancel to cancel a commande"})
+		return
+	}
+	if errors.Is(err, business.ErrInvalidAmount) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrInvalidTransition) || errors.Is(err, business.ErrItemsLocked) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		log.Println("[Handler] Erreur mise à jour commande :", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update commande"})
 		return
@@ -126,7 +301,113 @@ func (h *Handler) UpdateCommandeHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, updated)
 }
 
-// DeleteCommandeHandler traite DELETE /commandes/:id
+// ConfirmCommandeHandler traite POST /commandes/:id/confirm
+func (h *Handler) ConfirmCommandeHandler(c *gin.Context) {
+	h.changeStatus(c, models.StatusConfirmee)
+}
+
+// ShipCommandeHandler traite POST /commandes/:id/ship
+func (h *Handler) ShipCommandeHandler(c *gin.Context) {
+	h.changeStatus(c, models.StatusExpediee)
+}
+
+// DeliverCommandeHandler traite POST /commandes/:id/deliver
+func (h *Handler) DeliverCommandeHandler(c *gin.Context) {
+	h.changeStatus(c, models.StatusLivree)
+}
+
+// RefundCommandeHandler traite POST /commandes/:id/refund
+func (h *Handler) RefundCommandeHandler(c *gin.Context) {
+	h.changeStatus(c, models.StatusRemboursee)
+}
+
+// changeStatus applique une transition et répond 409 si elle est interdite.
+func (h *Handler) changeStatus(c *gin.Context, next models.Status) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	commande, err := h.CommandeService.ChangeStatus(c, id, next)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
+		return
+	}
+	if errors.Is(err, models.ErrInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[Handler] Erreur passage au statut %s : %v", next, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update commande status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, commande)
+}
+
+// GetCommandeHistoryHandler traite GET /commandes/:id/history
+func (h *Handler) GetCommandeHistoryHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	history, err := h.CommandeService.GetCommandeHistory(c, id)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
+		return
+	}
+	if err != nil {
+		log.Println("[Handler] Erreur récupération historique :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch commande history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// CancelCommandeHandler traite POST /commandes/:id/cancel ; canceled_by vaut
+// « admin » ou « client » selon le jeton d'administration reçu
+func (h *Handler) CancelCommandeHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	var input CancelCommandeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	commande, err := h.CommandeService.CancelCommande(c, id, input.Reason, actor(c))
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
+		return
+	}
+	if errors.Is(err, business.ErrReasonRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrInvalidTransition) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("[Handler] Erreur annulation commande :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not cancel commande"})
+		return
+	}
+
+	c.JSON(http.StatusOK, commande)
+}
+
+// DeleteCommandeHandler traite DELETE /commandes/:id (administrateurs :
+// suppression logique, la commande n'apparaît plus dans les listes)
 func (h *Handler) DeleteCommandeHandler(c *gin.Context) {
 	id := c.Param("id")
 	if _, err := uuid.Parse(id); err != nil {
@@ -134,7 +415,12 @@ func (h *Handler) DeleteCommandeHandler(c *gin.Context) {
 		return
 	}
 
-	if err := h.CommandeService.DeleteCommande(c, id); err != nil {
+	err := h.CommandeService.DeleteCommande(c, id)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "commande not found"})
+		return
+	}
+	if err != nil {
 		log.Println("[Handler] Erreur suppression commande :", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete commande"})
 		return
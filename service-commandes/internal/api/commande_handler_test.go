@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -13,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/business"
 )
@@ -22,51 +22,117 @@ var mockID = "11111111-1111-1111-1111-111111111111"
 // fakeCommandService simule un service fonctionnel
 type fakeCommandService struct{}
 
-func (f fakeCommandService) CreateCommande(_ context.Context, _ models.Commande) error {
-	return nil
+// unknownUserID n'existe pas dans la copie locale des utilisateurs
+const unknownUserID = "33333333-3333-3333-3333-333333333333"
+
+func (f fakeCommandService) CreateCommande(_ context.Context, commande models.Commande) (*models.Commande, error) {
+	if commande.UserID == unknownUserID {
+		return nil, business.ErrUnknownUser
+	}
+	commande.Amount = models.Total(commande.Items)
+	return &commande, nil
 }
 
-func (f fakeCommandService) GetAllCommandes(_ context.Context) ([]models.Commande, error) {
-	return []models.Commande{
-		{
-			ID:        mockID,
-			UserID:    "123e4567-e89b-12d3-a456-426614174000",
-			Product:   "Produit test",
-			Amount:    49.99,
-			Status:    "en_attente",
-			CreatedAt: time.Now().UTC(),
-		},
+func (f fakeCommandService) GetAllCommandes(_ context.Context, q models.ListQuery) (*models.CommandePage, error) {
+	commande := models.Commande{
+		ID:        mockID,
+		UserID:    "123e4567-e89b-12d3-a456-426614174000",
+		Items:     []models.OrderItem{{ProductID: "produit-test", Quantity: 1, UnitPrice: 4999}},
+		Amount:    4999,
+		Status:    "en_attente",
+		CreatedAt: time.Now().UTC(),
+	}
+	return &models.CommandePage{
+		Items:      []models.Commande{commande},
+		NextCursor: q.CursorAfter(commande).Encode(),
+	}, nil
+}
+
+func (f fakeCommandService) GetUserCommandes(ctx context.Context, userID string, q models.ListQuery) (*models.CommandePage, error) {
+	q.UserID = userID
+	return f.GetAllCommandes(ctx, q)
+}
+
+func (f fakeCommandService) GetUserSummary(_ context.Context, userID string) (*models.UserSummary, error) {
+	last := time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC)
+	return &models.UserSummary{
+		UserID:      userID,
+		OrderCount:  3,
+		TotalSpent:  []models.CurrencyTotal{{Currency: "EUR", Amount: 8997}},
+		LastOrderAt: &last,
 	}, nil
 }
 
+// listingCommandService retient la requête reçue par GetAllCommandes
+type listingCommandService struct {
+	fakeCommandService
+	query *models.ListQuery
+}
+
+func (f listingCommandService) GetAllCommandes(ctx context.Context, q models.ListQuery) (*models.CommandePage, error) {
+	*f.query = q
+	return f.fakeCommandService.GetAllCommandes(ctx, q)
+}
+
+func (f listingCommandService) GetUserCommandes(ctx context.Context, userID string, q models.ListQuery) (*models.CommandePage, error) {
+	q.UserID = userID
+	return f.GetAllCommandes(ctx, q)
+}
+
 func (f fakeCommandService) GetCommandeByID(_ context.Context, id string) (*models.Commande, error) {
 	if id != mockID {
-		return nil, errors.New("not found")
+		return nil, business.ErrNotFound
 	}
 	return &models.Commande{
 		ID:        mockID,
 		UserID:    "123e4567-e89b-12d3-a456-426614174000",
-		Product:   "Produit test",
-		Amount:    49.99,
+		Items:     []models.OrderItem{{ProductID: "produit-test", Quantity: 1, UnitPrice: 4999}},
+		Amount:    4999,
 		Status:    "en_attente",
 		CreatedAt: time.Now().UTC(),
 	}, nil
 }
 
-func (f fakeCommandService) UpdateCommande(_ context.Context, id string, _ models.Commande) error {
+func (f fakeCommandService) UpdateCommande(_ context.Context, id string, update models.Commande) (*models.Commande, error) {
 	if id != mockID {
-		return errors.New("not found")
+		return nil, business.ErrNotFound
 	}
-	return nil
+	update.ID = id
+	return &update, nil
+}
+
+func (f fakeCommandService) CancelCommande(_ context.Context, id, reason, canceledBy string) (*models.Commande, error) {
+	if id != mockID {
+		return nil, business.ErrNotFound
+	}
+	now := time.Now().UTC()
+	return &models.Commande{ID: mockID, Status: models.StatusAnnulee, CancelReason: reason, CanceledBy: canceledBy, CanceledAt: &now}, nil
 }
 
 func (f fakeCommandService) DeleteCommande(_ context.Context, id string) error {
 	if id != mockID {
-		return errors.New("not found")
+		return business.ErrNotFound
 	}
 	return nil
 }
 
+func (f fakeCommandService) ChangeStatus(_ context.Context, id string, next models.Status) (*models.Commande, error) {
+	if id != mockID {
+		return nil, business.ErrNotFound
+	}
+	if err := models.StatusEnAttente.TransitionTo(next); err != nil {
+		return nil, err
+	}
+	return &models.Commande{ID: mockID, Status: next}, nil
+}
+
+func (f fakeCommandService) GetCommandeHistory(_ context.Context, id string) ([]models.StatusChange, error) {
+	if id != mockID {
+		return nil, business.ErrNotFound
+	}
+	return []models.StatusChange{{To: models.StatusEnAttente, ChangedAt: time.Now().UTC()}}, nil
+}
+
 // === TESTS ===
 
 func TestCreateCommandeHandler_Success(t *testing.T) {
@@ -74,8 +140,10 @@ func TestCreateCommandeHandler_Success(t *testing.T) {
 
 	payload := map[string]interface{}{
 		"user_id": uuid.New().String(),
-		"product": "Test produit",
-		"amount":  49.99,
+		"items": []map[string]interface{}{
+			{"product_id": "clavier", "quantity": 1, "unit_price": 4999},
+			{"product_id": "souris", "quantity": 2, "unit_price": 500},
+		},
 	}
 	body, _ := json.Marshal(payload)
 
@@ -85,16 +153,85 @@ func TestCreateCommandeHandler_Success(t *testing.T) {
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusCreated, resp.Code)
+	var commande models.Commande
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &commande))
+	assert.Len(t, commande.Items, 2)
+	assert.Equal(t, money.Amount(5999), commande.Amount)
 }
 
 func TestGetAllCommandesHandler(t *testing.T) {
-	router := setupRouterWith(fakeCommandService{})
+	query := &models.ListQuery{}
+	router := setupRouterWith(listingCommandService{query: query})
 
 	req, _ := http.NewRequest(http.MethodGet, "/commandes", nil)
 	resp := httptest.NewRecorder()
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusOK, resp.Code)
+	var page models.CommandePage
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// plus récentes d'abord par défaut
+	assert.Equal(t, models.SortByCreatedAt, query.Sort)
+	assert.True(t, query.Desc)
+}
+
+func TestGetAllCommandesHandler_FiltersAndCursor(t *testing.T) {
+	query := &models.ListQuery{}
+	router := setupRouterWith(listingCommandService{query: query})
+
+	cursor := models.Cursor{Sort: models.SortByAmount, Amount: 1500, ID: mockID}.Encode()
+	req, _ := http.NewRequest(http.MethodGet, "/commandes?user_id=123e4567-e89b-12d3-a456-426614174000"+
+		"&status=confirmee&created_from=2025-04-01T00:00:00Z&created_to=2025-05-01T00:00:00Z"+
+		"&currency=EUR&min_amount=1000&max_amount=5000&sort=amount&limit=10&cursor="+cursor, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", query.UserID)
+	assert.Equal(t, models.StatusConfirmee, query.Status)
+	assert.Equal(t, "EUR", query.Currency)
+	if assert.NotNil(t, query.CreatedFrom) && assert.NotNil(t, query.CreatedTo) {
+		assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), query.CreatedFrom.UTC())
+		assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), query.CreatedTo.UTC())
+	}
+	if assert.NotNil(t, query.MinAmount) && assert.NotNil(t, query.MaxAmount) {
+		assert.Equal(t, money.Amount(1000), *query.MinAmount)
+		assert.Equal(t, money.Amount(5000), *query.MaxAmount)
+	}
+	assert.Equal(t, models.SortByAmount, query.Sort)
+	assert.False(t, query.Desc)
+	assert.Equal(t, 10, query.Limit)
+	if assert.NotNil(t, query.Cursor) {
+		assert.Equal(t, mockID, query.Cursor.ID)
+		assert.Equal(t, money.Amount(1500), query.Cursor.Amount)
+	}
+}
+
+func TestGetAllCommandesHandler_InvalidQuery(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	amountCursor := models.Cursor{Sort: models.SortByAmount, Amount: 1500, ID: mockID}.Encode()
+	for name, query := range map[string]string{
+		"limite trop grande":     "limit=1000",
+		"tri inconnu":            "sort=product",
+		"date invalide":          "created_from=hier",
+		"curseur illisible":      "cursor=pas-un-curseur",
+		"curseur d'un autre tri": "sort=-created_at&cursor=" + amountCursor,
+		"devise inconnue":        "currency=ABC",
+		"montant sans devise":    "min_amount=1000",
+		"tri sans devise":        "sort=-amount",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/commandes?"+query, nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	}
 }
 
 func TestGetCommandeByIDHandler_Success(t *testing.T) {
@@ -112,9 +249,8 @@ func TestUpdateCommandeHandler_Success(t *testing.T) {
 
 	payload := map[string]interface{}{
 		"user_id": uuid.New().String(),
-		"product": "Produit modifié",
-		"amount":  59.99,
-		"status": "en_attente",
+		"items":   []map[string]interface{}{{"product_id": "clavier", "quantity": 1, "unit_price": 5999}},
+		"status":  "en_attente",
 	}
 	body, _ := json.Marshal(payload)
 
@@ -136,12 +272,132 @@ func TestDeleteCommandeHandler_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Code)
 }
 
+func TestGetCommandeByIDHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/commandes/22222222-2222-2222-2222-222222222222", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestUpdateCommandeHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"items":  []map[string]interface{}{{"product_id": "clavier", "quantity": 1, "unit_price": 5999}},
+		"status": "en_attente",
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/commandes/22222222-2222-2222-2222-222222222222", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestCancelCommandeHandler_Success(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	// canceled_by n'est pas lu dans le corps : sans jeton, l'auteur est le client
+	body, _ := json.Marshal(map[string]string{"reason": "rupture de stock", "canceled_by": "support"})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/cancel", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var commande models.Commande
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &commande))
+	assert.Equal(t, models.StatusAnnulee, commande.Status)
+	assert.Equal(t, "rupture de stock", commande.CancelReason)
+	assert.Equal(t, ActorClient, commande.CanceledBy)
+}
+
+func TestCancelCommandeHandler_AdminActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/commandes/:id/cancel", IdentifyActor("secret"), NewHandler(fakeCommandService{}).CancelCommandeHandler)
+
+	for token, expected := range map[string]string{
+		"":        ActorClient,
+		"mauvais": ActorClient,
+		"secret":  ActorAdmin,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/cancel", bytes.NewBufferString(`{"reason": "doublon"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(AdminTokenHeader, token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		var commande models.Commande
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &commande))
+		assert.Equal(t, expected, commande.CanceledBy, "jeton %q", token)
+	}
+}
+
+func TestCancelCommandeHandler_MissingReason(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/cancel", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestDeleteCommandeHandler_RequiresAdminToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := NewHandler(fakeCommandService{})
+	router.DELETE("/commandes/:id", AdminOnly("secret"), handler.DeleteCommandeHandler)
+
+	for token, expected := range map[string]int{
+		"":        http.StatusForbidden,
+		"mauvais": http.StatusForbidden,
+		"secret":  http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodDelete, "/commandes/"+mockID, nil)
+		req.Header.Set(AdminTokenHeader, token)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, expected, resp.Code, "jeton %q", token)
+	}
+}
+
+func TestAdminOnly_ClosedWithoutConfiguredToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/commandes/:id", AdminOnly(""), NewHandler(fakeCommandService{}).DeleteCommandeHandler)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/commandes/"+mockID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+func TestDeleteCommandeHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodDelete, "/commandes/22222222-2222-2222-2222-222222222222", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
 func TestCreateCommandeHandler_MissingFields(t *testing.T) {
 	router := setupRouterWith(fakeCommandService{})
 
 	payload := map[string]interface{}{
 		"user_id": uuid.New().String(),
-		"amount":  10.00, // manque product
+		"items":   []map[string]interface{}{}, // au moins une ligne
 	}
 	body, _ := json.Marshal(payload)
 
@@ -156,7 +412,7 @@ func TestCreateCommandeHandler_MissingFields(t *testing.T) {
 func TestCreateCommandeHandler_InvalidJSON(t *testing.T) {
 	router := setupRouterWith(fakeCommandService{})
 
-	badJSON := `{"user_id": "ok", "product": }`
+	badJSON := `{"user_id": "ok", "items": }`
 
 	req, _ := http.NewRequest("POST", "/commandes", bytes.NewBufferString(badJSON))
 	req.Header.Set("Content-Type", "application/json")
@@ -166,6 +422,184 @@ func TestCreateCommandeHandler_InvalidJSON(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 }
 
+func TestConfirmCommandeHandler_Success(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/confirm", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var commande models.Commande
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &commande))
+	assert.Equal(t, models.StatusConfirmee, commande.Status)
+}
+
+func TestDeliverCommandeHandler_IllegalTransition(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/deliver", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+// canceledCommandService simule une commande déjà annulée
+type canceledCommandService struct {
+	fakeCommandService
+}
+
+func (f canceledCommandService) ChangeStatus(_ context.Context, id string, next models.Status) (*models.Commande, error) {
+	if err := models.StatusAnnulee.TransitionTo(next); err != nil {
+		return nil, err
+	}
+	return &models.Commande{ID: id, Status: next}, nil
+}
+
+func TestRefundCommandeHandler_CanceledOrderConflict(t *testing.T) {
+	router := setupRouterWith(canceledCommandService{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/"+mockID+"/refund", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+func TestShipCommandeHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes/22222222-2222-2222-2222-222222222222/ship", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestUpdateCommandeHandler_UnknownStatus(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"items":  []map[string]interface{}{{"product_id": "clavier", "quantity": 1, "unit_price": 5999}},
+		"status": "perdue",
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/commandes/"+mockID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestGetCommandeHistoryHandler(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/commandes/"+mockID+"/history", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var history []models.StatusChange
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &history))
+	assert.Len(t, history, 1)
+}
+
+func TestCreateCommandeHandler_InvalidItem(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id": uuid.New().String(),
+		"items":   []map[string]interface{}{{"product_id": "clavier", "quantity": 0, "unit_price": 10}},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestCreateCommandeHandler_UnknownUser(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id": unknownUserID,
+		"items":   []map[string]interface{}{{"product_id": "clavier", "quantity": 1, "unit_price": 4999}},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+}
+
+func TestCreateCommandeHandler_DecimalPriceIsRejected(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	// les prix sont en unités mineures : 49.99 doit être envoyé comme 4999
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id": uuid.New().String(),
+		"items":   []map[string]interface{}{{"product_id": "clavier", "quantity": 1, "unit_price": 49.99}},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, "/commandes", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestGetUserCommandesHandler(t *testing.T) {
+	query := &models.ListQuery{}
+	router := setupRouterWith(listingCommandService{query: query})
+
+	userID := "123e4567-e89b-12d3-a456-426614174000"
+	req, _ := http.NewRequest(http.MethodGet, "/users/"+userID+"/commandes?status=livree&limit=5", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var page models.CommandePage
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &page))
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, userID, query.UserID)
+	assert.Equal(t, models.StatusLivree, query.Status)
+	assert.Equal(t, 5, query.Limit)
+}
+
+func TestGetUserCommandesHandler_InvalidUUID(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/abc/commandes", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestGetUserSummaryHandler(t *testing.T) {
+	router := setupRouterWith(fakeCommandService{})
+
+	userID := "123e4567-e89b-12d3-a456-426614174000"
+	req, _ := http.NewRequest(http.MethodGet, "/users/"+userID+"/commandes/summary", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var summary models.UserSummary
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &summary))
+	assert.Equal(t, userID, summary.UserID)
+	assert.Equal(t, 3, summary.OrderCount)
+	assert.Equal(t, []models.CurrencyTotal{{Currency: "EUR", Amount: 8997}}, summary.TotalSpent)
+	assert.NotNil(t, summary.LastOrderAt)
+}
+
 // setupRouterWith est une fonction utilitaire locale aux tests
 func setupRouterWith(service business.CommandeService) *gin.Engine {
 	router := gin.Default()
@@ -176,6 +610,14 @@ func setupRouterWith(service business.CommandeService) *gin.Engine {
 	router.GET("/commandes/:id", handler.GetCommandeByIDHandler)
 	router.PUT("/commandes/:id", handler.UpdateCommandeHandler)
 	router.DELETE("/commandes/:id", handler.DeleteCommandeHandler)
+	router.POST("/commandes/:id/confirm", handler.ConfirmCommandeHandler)
+	router.POST("/commandes/:id/ship", handler.ShipCommandeHandler)
+	router.POST("/commandes/:id/deliver", handler.DeliverCommandeHandler)
+	router.POST("/commandes/:id/refund", handler.RefundCommandeHandler)
+	router.POST("/commandes/:id/cancel", handler.CancelCommandeHandler)
+	router.GET("/commandes/:id/history", handler.GetCommandeHistoryHandler)
+	router.GET("/users/:id/commandes", handler.GetUserCommandesHandler)
+	router.GET("/users/:id/commandes/summary", handler.GetUserSummaryHandler)
 
 	return router
 }
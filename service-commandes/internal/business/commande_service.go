@@ -2,111 +2,266 @@ package business
 
 import (
 	"context"
-	"encoding/json"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/streadway/amqp"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/repository"
 )
 
-// CommandeCreatedEvent représente un message CommandeCreated publié dans RabbitMQ.
-type CommandeCreatedEvent struct {
-	EventType string          `json:"eventType"`
-	Version   string          `json:"version"`
-	Timestamp string          `json:"timestamp"`
-	Payload   models.Commande `json:"payload"`
-}
+// ErrNotFound est retournée lorsque la commande demandée n'existe pas.
+var ErrNotFound = repository.ErrNotFound
+
+// ErrReasonRequired est retournée pour une annulation sans raison.
+var ErrReasonRequired = errors.New("cancellation requires a reason")
+
+// ErrInvalidAmount est retournée pour une ligne ou un total qui n'est pas
+// strictement positif.
+var ErrInvalidAmount = errors.New("amounts must be positive")
+
+// ErrUnknownCurrency est retournée pour une devise hors ISO 4217.
+var ErrUnknownCurrency = money.ErrUnknownCurrency
+
+// ErrItemsLocked est retournée lorsqu'on modifie les lignes d'une commande
+// qui n'est plus en attente.
+var ErrItemsLocked = errors.New("items can only be changed while the commande is en_attente")
 
 // Service est l’implémentation concrète de l’interface CommandeService.
 type Service struct{}
 
 var (
-	insertCommande   = repository.InsertCommande
-	getAllCommandes  = repository.GetAllCommandes
-	getCommandeByID  = repository.GetCommandeByID
-	updateCommande   = repository.UpdateCommande
-	deleteCommande   = repository.DeleteCommande
+	insertCommande     = repository.InsertCommande
+	listCommandes      = repository.ListCommandes
+	getCommandeByID    = repository.GetCommandeByID
+	updateCommande     = repository.UpdateCommande
+	softDeleteCommande = repository.SoftDeleteCommande
+	getStatusHistory   = repository.GetStatusHistory
+	getUserSummary     = repository.GetUserSummary
 )
 
-// CreateCommande insère la commande en base et publie l'événement.
-func (s Service) CreateCommande(ctx context.Context, commande models.Commande) error {
-	if err := insertCommande(commande); err != nil {
-		return err
+// CreateCommande vérifie l'utilisateur sur la copie locale (ErrUnknownUser),
+// calcule le montant à partir des lignes (devise EUR par défaut) puis insère
+// la commande, ses lignes, son état initial et son événement OrderCreated
+// dans la même transaction ; le relais outbox se charge ensuite de la
+// publication.
+func (s Service) CreateCommande(ctx context.Context, commande models.Commande) (*models.Commande, error) {
+	if err := checkUser(ctx, commande.UserID); err != nil {
+		return nil, err
+	}
+
+	commande.Status = models.StatusEnAttente // toute commande démarre en attente
+	if commande.Currency == "" {
+		commande.Currency = events.DefaultCurrency
+	}
+	total, err := priceItems(commande.Items, commande.Currency)
+	if err != nil {
+		return nil, err
+	}
+	commande.Amount = total
+
+	msg, err := newCommandeCreatedMessage(ctx, commande)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertCommande(ctx, commande, msg); err != nil {
+		return nil, err
+	}
+	return &commande, nil
+}
+
+// GetAllCommandes retourne une page de commandes filtrée et triée selon q
+// (par date de création et DefaultPageSize par défaut) ; la page suivante
+// s'obtient avec le curseur NextCursor.
+func (s Service) GetAllCommandes(ctx context.Context, q models.ListQuery) (*models.CommandePage, error) {
+	if q.Sort == "" {
+		q.Sort = models.SortByCreatedAt
+	}
+	if q.Limit <= 0 || q.Limit > models.MaxPageSize {
+		q.Limit = models.DefaultPageSize
 	}
-	return publishCommandeCreated(commande)
+
+	// une ligne de plus indique s'il reste une page
+	limit := q.Limit
+	q.Limit++
+	commandes, err := listCommandes(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.CommandePage{Items: commandes}
+	if len(commandes) > limit {
+		page.Items = commandes[:limit]
+		page.NextCursor = q.CursorAfter(page.Items[limit-1]).Encode()
+	}
+	if page.Items == nil {
+		page.Items = []models.Commande{}
+	}
+	return page, nil
 }
 
-// GetAllCommandes retourne toutes les commandes.
-func (s Service) GetAllCommandes(ctx context.Context) ([]models.Commande, error) {
-	return getAllCommandes()
+// GetUserCommandes retourne une page des commandes d'un utilisateur, avec
+// les mêmes filtres, tris et curseurs que GetAllCommandes.
+func (s Service) GetUserCommandes(ctx context.Context, userID string, q models.ListQuery) (*models.CommandePage, error) {
+	q.UserID = userID
+	return s.GetAllCommandes(ctx, q)
+}
+
+// GetUserSummary retourne le nombre de commandes d'un utilisateur, son total
+// dépensé par devise et la date de sa dernière commande.
+func (s Service) GetUserSummary(ctx context.Context, userID string) (*models.UserSummary, error) {
+	summary, err := getUserSummary(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
 }
 
 // GetCommandeByID retourne une commande par ID.
 func (s Service) GetCommandeByID(ctx context.Context, id string) (*models.Commande, error) {
-	c, err := getCommandeByID(id)
+	c, err := getCommandeByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return &c, nil
 }
 
-// UpdateCommande met à jour une commande existante.
-func (s Service) UpdateCommande(ctx context.Context, id string, updated models.Commande) error {
-	updated.ID = id // assurer que l’ID reste le même
-	return updateCommande(updated)
-}
-
-// DeleteCommande supprime une commande.
-func (s Service) DeleteCommande(ctx context.Context, id string) error {
-	return deleteCommande(id)
+// UpdateCommande remplace les lignes d'une commande, recalcule son montant et
+// retourne sa version enregistrée ; l'événement OrderUpdated est écrit dans
+// la même transaction. Les lignes ne changent qu'en attente (ErrItemsLocked)
+// et un statut fourni doit respecter le cycle de vie
+// (models.ErrInvalidTransition).
+func (s Service) UpdateCommande(ctx context.Context, id string, update models.Commande) (*models.Commande, error) {
+	c, err := updateCommande(ctx, id, func(current models.Commande) (models.Commande, []outbox.Message, error) {
+		if current.Status != models.StatusEnAttente && !slices.Equal(update.Items, current.Items) {
+			return current, nil, ErrItemsLocked
+		}
+		total, err := priceItems(update.Items, current.Currency)
+		if err != nil {
+			return current, nil, err
+		}
+		updated := current
+		updated.Items = update.Items
+		updated.Amount = total
+		if update.Status != "" && update.Status != current.Status {
+			if update.Status == models.StatusAnnulee {
+				return current, nil, ErrReasonRequired // passer par CancelCommande
+			}
+			if err := current.Status.TransitionTo(update.Status); err != nil {
+				return current, nil, err
+			}
+			updated.Status = update.Status
+		}
+		return withOrderUpdated(ctx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
-// publication RabbitMQ
-var publishCommandeCreated = func(commande models.Commande) error {
-	rabbitURL := os.Getenv("RABBITMQ_URL")
-	conn, err := amqp.Dial(rabbitURL)
+// ChangeStatus fait passer une commande à l'état next si le cycle de vie le
+// permet ; l'historique et l'événement OrderUpdated sont écrits dans la même
+// transaction.
+func (s Service) ChangeStatus(ctx context.Context, id string, next models.Status) (*models.Commande, error) {
+	c, err := updateCommande(ctx, id, func(current models.Commande) (models.Commande, []outbox.Message, error) {
+		if err := current.Status.TransitionTo(next); err != nil {
+			return current, nil, err
+		}
+		updated := current
+		updated.Status = next
+		return withOrderUpdated(ctx, updated)
+	})
 	if err != nil {
-		log.Println("[RabbitMQ] Connexion échouée :", err)
-		return err
+		return nil, err
 	}
-	defer conn.Close()
+	return &c, nil
+}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		log.Println("[RabbitMQ] Échec ouverture channel :", err)
-		return err
+// GetCommandeHistory retourne l'historique des états d'une commande.
+func (s Service) GetCommandeHistory(ctx context.Context, id string) ([]models.StatusChange, error) {
+	if _, err := getCommandeByID(ctx, id); err != nil {
+		return nil, err
 	}
-	defer ch.Close()
+	return getStatusHistory(ctx, id)
+}
 
-	event := CommandeCreatedEvent{
-		EventType: "CommandeCreated",
-		Version:   "1.0",
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Payload:   commande,
+// priceItems vérifie la devise et les montants des lignes puis retourne
+// leur total.
+func priceItems(items []models.OrderItem, currency string) (money.Amount, error) {
+	if !money.IsCurrency(currency) {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 || item.UnitPrice <= 0 {
+			return 0, ErrInvalidAmount
+		}
 	}
+	total := models.Total(items)
+	if total <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	return total, nil
+}
 
-	body, err := json.Marshal(event)
+// withOrderUpdated associe à une commande modifiée son événement OrderUpdated.
+func withOrderUpdated(ctx context.Context, c models.Commande) (models.Commande, []outbox.Message, error) {
+	msg, err := outbox.NewMessage(ctx, events.RoutingKeyOrderUpdated, toOrderUpdatedEvent(c, time.Now().UTC()))
 	if err != nil {
-		log.Println("[RabbitMQ] JSON invalide :", err)
-		return err
+		return c, nil, err
+	}
+	return c, []outbox.Message{msg}, nil
+}
+
+// CancelCommande annule une commande en conservant la raison et l'auteur ;
+// l'historique et les événements OrderUpdated (nouvel état, comme pour tout
+// changement de statut) et OrderCanceled (raison) sont écrits dans la même
+// transaction. La commande reste consultable.
+func (s Service) CancelCommande(ctx context.Context, id, reason, canceledBy string) (*models.Commande, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrReasonRequired
 	}
 
-	err = ch.Publish(
-		"events",           // exchange
-		"commande.created", // routing key
-		false, false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
+	c, err := updateCommande(ctx, id, func(current models.Commande) (models.Commande, []outbox.Message, error) {
+		if err := current.Status.TransitionTo(models.StatusAnnulee); err != nil {
+			return current, nil, err
+		}
 
+		now := time.Now().UTC()
+		canceled := current
+		canceled.Status = models.StatusAnnulee
+		canceled.CancelReason = reason
+		canceled.CanceledBy = canceledBy
+		canceled.CanceledAt = &now
+
+		_, messages, err := withOrderUpdated(ctx, canceled)
+		if err != nil {
+			return current, nil, err
+		}
+		msg, err := outbox.NewMessage(ctx, events.RoutingKeyOrderCanceled, toOrderCanceledEvent(canceled, reason, now))
+		if err != nil {
+			return current, nil, err
+		}
+		return canceled, append(messages, msg), nil
+	})
 	if err != nil {
-		log.Println("[RabbitMQ] Erreur publication :", err)
+		return nil, err
 	}
+	return &c, nil
+}
+
+// DeleteCommande masque une commande (suppression logique réservée aux
+// administrateurs) ; son historique est conservé et aucun événement n'est émis.
+func (s Service) DeleteCommande(ctx context.Context, id string) error {
+	return softDeleteCommande(ctx, id)
+}
 
-	return err
+// newCommandeCreatedMessage prépare l'événement OrderCreated pour l'outbox.
+func newCommandeCreatedMessage(ctx context.Context, commande models.Commande) (outbox.Message, error) {
+	return outbox.NewMessage(ctx, events.RoutingKeyOrderCreated, toOrderCreatedEvent(commande))
 }
@@ -2,45 +2,339 @@ package business
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/repository"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateCommande_MockDependencies(t *testing.T) {
+	mockReplicaUsers(t, models.User{UserID: "123e4567-e89b-12d3-a456-426614174000"})
+
 	// 🔁 Mock InsertCommande
 	originalInsert := insertCommande
 	defer func() { insertCommande = originalInsert }()
 
-	insertCommande = func(cmd models.Commande) error {
-		assert.Equal(t, "Souris ergonomique", cmd.Product)
+	insertCommande = func(_ context.Context, cmd models.Commande, messages ...outbox.Message) error {
+		assert.Len(t, cmd.Items, 2)
 		assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", cmd.UserID)
-		assert.Equal(t, 39.99, cmd.Amount)
+		assert.Equal(t, money.Amount(4997), cmd.Amount) // calculé à partir des lignes
+
+		// l'événement est écrit dans l'outbox avec la commande
+		if assert.Len(t, messages, 1) {
+			var event events.OrderCreatedEvent
+			assert.Equal(t, "commande.created", messages[0].RoutingKey)
+			assert.NoError(t, json.Unmarshal(messages[0].Payload, &event))
+			assert.Equal(t, events.TypeOrderCreated, event.EventType)
+			assert.Equal(t, "test-id-commande", event.Payload.OrderID)
+			assert.Equal(t, cmd.UserID, event.Payload.UserID)
+			assert.Equal(t, []events.OrderItem{
+				{ProductID: "souris-ergo", Quantity: 1, UnitPrice: 3999},
+				{ProductID: "tapis", Quantity: 2, UnitPrice: 499},
+			}, event.Payload.Items)
+			assert.Equal(t, money.Amount(4997), event.Payload.TotalAmount)
+			assert.Equal(t, "EUR", event.Payload.Currency)
+			assert.Equal(t, "4.0", event.Version)
+		}
 		return nil
 	}
 
-	// 🔁 Mock publishCommandeCreated
-	originalPublisher := publishCommandeCreated
-	defer func() { publishCommandeCreated = originalPublisher }()
+	cmd := models.Commande{
+		ID:     "test-id-commande",
+		UserID: "123e4567-e89b-12d3-a456-426614174000",
+		Items: []models.OrderItem{
+			{ProductID: "souris-ergo", Quantity: 1, UnitPrice: 3999},
+			{ProductID: "tapis", Quantity: 2, UnitPrice: 499},
+		},
+		Amount:    1000, // ignoré : le montant est recalculé
+		Status:    "en_attente",
+		CreatedAt: time.Now().UTC(),
+	}
 
-	publishCommandeCreated = func(cmd models.Commande) error {
-		assert.Equal(t, "en_attente", cmd.Status)
-		assert.NotEmpty(t, cmd.ID)
+	service := Service{}
+	created, err := service.CreateCommande(context.Background(), cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, money.Amount(4997), created.Amount)
+}
+
+func TestCreateCommande_RejectsInvalidAmounts(t *testing.T) {
+	mockReplicaUsers(t, models.User{UserID: "u-1"})
+	originalInsert := insertCommande
+	defer func() { insertCommande = originalInsert }()
+	insertCommande = func(context.Context, models.Commande, ...outbox.Message) error {
+		t.Fatal("une commande invalide ne doit pas être enregistrée")
 		return nil
 	}
 
-	cmd := models.Commande{
+	cases := map[string]struct {
+		currency string
+		items    []models.OrderItem
+		want     error
+	}{
+		"prix nul":        {"EUR", []models.OrderItem{{ProductID: "p-1", Quantity: 1, UnitPrice: 0}}, ErrInvalidAmount},
+		"prix négatif":    {"EUR", []models.OrderItem{{ProductID: "p-1", Quantity: 1, UnitPrice: -100}}, ErrInvalidAmount},
+		"sans ligne":      {"EUR", nil, ErrInvalidAmount},
+		"devise inconnue": {"EURO", []models.OrderItem{{ProductID: "p-1", Quantity: 1, UnitPrice: 100}}, ErrUnknownCurrency},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Service{}.CreateCommande(context.Background(), models.Commande{
+				ID:       "test-id-commande",
+				UserID:   "u-1",
+				Currency: tc.currency,
+				Items:    tc.items,
+			})
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+}
+
+// mockStoredCommande simule une commande existante en base.
+func mockStoredCommande(t *testing.T) models.Commande {
+	stored := models.Commande{
 		ID:        "test-id-commande",
 		UserID:    "123e4567-e89b-12d3-a456-426614174000",
-		Product:   "Souris ergonomique",
-		Amount:    39.99,
+		Items:     []models.OrderItem{{ProductID: "souris-ergo", Quantity: 1, UnitPrice: 3999}},
+		Amount:    3999,
+		Currency:  "EUR",
 		Status:    "en_attente",
-		CreatedAt: time.Now().UTC(),
+		CreatedAt: time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC),
 	}
 
-	service := Service{}
-	err := service.CreateCommande(context.Background(), cmd)
+	originalGet := getCommandeByID
+	t.Cleanup(func() { getCommandeByID = originalGet })
+	getCommandeByID = func(_ context.Context, id string) (models.Commande, error) {
+		if id != stored.ID {
+			return models.Commande{}, ErrNotFound
+		}
+		return stored, nil
+	}
+	return stored
+}
+
+// mockUpdateCommande simule le verrouillage de stored et capture les
+// événements écrits par apply.
+func mockUpdateCommande(t *testing.T, stored models.Commande) *[]outbox.Message {
+	originalUpdate := updateCommande
+	t.Cleanup(func() { updateCommande = originalUpdate })
+
+	written := &[]outbox.Message{}
+	updateCommande = func(_ context.Context, id string, apply repository.UpdateFunc) (models.Commande, error) {
+		if id != stored.ID {
+			return models.Commande{}, ErrNotFound
+		}
+		updated, messages, err := apply(stored)
+		if err != nil {
+			return models.Commande{}, err
+		}
+		*written = messages
+		return updated, nil
+	}
+	return written
+}
+
+func TestUpdateCommande_WritesOrderUpdated(t *testing.T) {
+	stored := mockStoredCommande(t)
+	written := mockUpdateCommande(t, stored)
+
+	updated, err := Service{}.UpdateCommande(context.Background(), stored.ID, models.Commande{
+		UserID: "autre-client",
+		Items:  []models.OrderItem{{ProductID: "clavier", Quantity: 1, UnitPrice: 5999}},
+		Status: models.StatusConfirmee,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, stored.ID, updated.ID)
+	assert.Equal(t, stored.UserID, updated.UserID)
+	assert.Equal(t, stored.CreatedAt, updated.CreatedAt)
+	assert.Equal(t, models.StatusConfirmee, updated.Status)
+
+	if assert.Len(t, *written, 1) {
+		var event events.OrderUpdatedEvent
+		assert.Equal(t, "commande.updated", (*written)[0].RoutingKey)
+		assert.NoError(t, json.Unmarshal((*written)[0].Payload, &event))
+		assert.Equal(t, events.TypeOrderUpdated, event.EventType)
+		assert.Equal(t, stored.UserID, event.Payload.UserID)
+		assert.Equal(t, money.Amount(5999), event.Payload.TotalAmount)
+		assert.Equal(t, "EUR", event.Payload.Currency)
+		assert.Equal(t, []events.OrderItem{{ProductID: "clavier", Quantity: 1, UnitPrice: 5999}}, event.Payload.Items)
+		assert.Equal(t, "confirmee", event.Payload.Status)
+		assert.Equal(t, stored.CreatedAt, event.Payload.OrderDate)
+	}
+}
+
+func TestUpdateCommande_IllegalStatusIsRejected(t *testing.T) {
+	stored := mockStoredCommande(t)
+	written := mockUpdateCommande(t, stored)
+
+	_, err := Service{}.UpdateCommande(context.Background(), stored.ID, models.Commande{
+		Items:  stored.Items,
+		Status: models.StatusLivree,
+	})
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	assert.Empty(t, *written)
+}
+
+func TestUpdateCommande_ItemsLockedOnceConfirmed(t *testing.T) {
+	stored := mockStoredCommande(t)
+	stored.Status = models.StatusConfirmee
+	written := mockUpdateCommande(t, stored)
+
+	_, err := Service{}.UpdateCommande(context.Background(), stored.ID, models.Commande{
+		Items: []models.OrderItem{{ProductID: "clavier", Quantity: 1, UnitPrice: 5999}},
+	})
+	assert.ErrorIs(t, err, ErrItemsLocked)
+	assert.Empty(t, *written)
+
+	// les mêmes lignes restent acceptées pour faire avancer le statut
+	updated, err := Service{}.UpdateCommande(context.Background(), stored.ID, models.Commande{
+		Items:  stored.Items,
+		Status: models.StatusExpediee,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusExpediee, updated.Status)
+}
+
+func TestChangeStatus(t *testing.T) {
+	stored := mockStoredCommande(t)
+	written := mockUpdateCommande(t, stored)
+
+	confirmed, err := Service{}.ChangeStatus(context.Background(), stored.ID, models.StatusConfirmee)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusConfirmee, confirmed.Status)
+	assert.Len(t, *written, 1)
+
+	_, err = Service{}.ChangeStatus(context.Background(), stored.ID, models.StatusLivree)
+	var transitionErr *models.TransitionError
+	if assert.ErrorAs(t, err, &transitionErr) {
+		assert.Equal(t, models.StatusEnAttente, transitionErr.From)
+		assert.Equal(t, models.StatusLivree, transitionErr.To)
+	}
+}
+
+func TestCancelCommande_WritesOrderUpdatedAndOrderCanceled(t *testing.T) {
+	stored := mockStoredCommande(t)
+	written := mockUpdateCommande(t, stored)
+
+	canceled, err := Service{}.CancelCommande(context.Background(), stored.ID, "rupture de stock", "support")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusAnnulee, canceled.Status)
+	assert.Equal(t, "rupture de stock", canceled.CancelReason)
+	assert.Equal(t, "support", canceled.CanceledBy)
+	assert.NotNil(t, canceled.CanceledAt)
+
+	// les consommateurs d'OrderUpdated voient le changement de statut comme
+	// pour les autres transitions, dans la même écriture que OrderCanceled
+	if assert.Len(t, *written, 2) {
+		var updated events.OrderUpdatedEvent
+		assert.Equal(t, "commande.updated", (*written)[0].RoutingKey)
+		assert.NoError(t, json.Unmarshal((*written)[0].Payload, &updated))
+		assert.Equal(t, string(models.StatusAnnulee), updated.Payload.Status)
+
+		var event events.OrderCanceledEvent
+		assert.Equal(t, "commande.canceled", (*written)[1].RoutingKey)
+		assert.NoError(t, json.Unmarshal((*written)[1].Payload, &event))
+		assert.Equal(t, events.TypeOrderCanceled, event.EventType)
+		assert.Equal(t, stored.UserID, event.Payload.UserID)
+		assert.Equal(t, "rupture de stock", event.Payload.Reason)
+	}
+}
+
+func TestCancelCommande_RequiresReason(t *testing.T) {
+	stored := mockStoredCommande(t)
+	written := mockUpdateCommande(t, stored)
+
+	_, err := Service{}.CancelCommande(context.Background(), stored.ID, "  ", "support")
+	assert.ErrorIs(t, err, ErrReasonRequired)
+	assert.Empty(t, *written)
+}
+
+func TestCancelCommande_ShippedOrderCannotBeCanceled(t *testing.T) {
+	stored := mockStoredCommande(t)
+	stored.Status = models.StatusExpediee
+	mockUpdateCommande(t, stored)
+
+	_, err := Service{}.CancelCommande(context.Background(), stored.ID, "trop tard", "client")
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+}
+
+func TestUpdateCommande_CancelRequiresDedicatedEndpoint(t *testing.T) {
+	stored := mockStoredCommande(t)
+	mockUpdateCommande(t, stored)
+
+	_, err := Service{}.UpdateCommande(context.Background(), stored.ID, models.Commande{
+		Items:  stored.Items,
+		Status: models.StatusAnnulee,
+	})
+	assert.ErrorIs(t, err, ErrReasonRequired)
+}
+
+func TestGetAllCommandes_PagesWithCursor(t *testing.T) {
+	originalList := listCommandes
+	defer func() { listCommandes = originalList }()
+
+	var got models.ListQuery
+	listCommandes = func(_ context.Context, q models.ListQuery) ([]models.Commande, error) {
+		got = q
+		commandes := make([]models.Commande, q.Limit)
+		for i := range commandes {
+			commandes[i] = models.Commande{ID: string(rune('a' + i)), Amount: money.Amount(100 * (i + 1))}
+		}
+		return commandes, nil
+	}
+
+	page, err := Service{}.GetAllCommandes(context.Background(), models.ListQuery{Sort: models.SortByAmount, Limit: 2})
+	assert.NoError(t, err)
+
+	// une ligne de plus est demandée pour savoir s'il reste une page
+	assert.Equal(t, 3, got.Limit)
+	assert.Len(t, page.Items, 2)
+
+	cursor, err := models.DecodeCursor(page.NextCursor, models.SortByAmount, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", cursor.ID)
+	assert.Equal(t, money.Amount(200), cursor.Amount)
+}
+
+func TestGetAllCommandes_LastPageHasNoCursor(t *testing.T) {
+	originalList := listCommandes
+	defer func() { listCommandes = originalList }()
+
+	var got models.ListQuery
+	listCommandes = func(_ context.Context, q models.ListQuery) ([]models.Commande, error) {
+		got = q
+		return nil, nil
+	}
+
+	page, err := Service{}.GetAllCommandes(context.Background(), models.ListQuery{Limit: 1000})
+	assert.NoError(t, err)
+	assert.Empty(t, page.NextCursor)
+	assert.NotNil(t, page.Items) // sérialisé en [] et non null
+
+	// valeurs par défaut
+	assert.Equal(t, models.SortByCreatedAt, got.Sort)
+	assert.Equal(t, models.DefaultPageSize+1, got.Limit)
+}
+
+func TestGetUserCommandes_ScopesToUser(t *testing.T) {
+	originalList := listCommandes
+	defer func() { listCommandes = originalList }()
+
+	var got models.ListQuery
+	listCommandes = func(_ context.Context, q models.ListQuery) ([]models.Commande, error) {
+		got = q
+		return nil, nil
+	}
+
+	// un user_id passé dans la requête ne permet pas de lire un autre client
+	_, err := Service{}.GetUserCommandes(context.Background(), "u-1", models.ListQuery{UserID: "u-2", Status: models.StatusLivree})
 	assert.NoError(t, err)
+	assert.Equal(t, "u-1", got.UserID)
+	assert.Equal(t, models.StatusLivree, got.Status)
 }
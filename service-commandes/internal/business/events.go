@@ -0,0 +1,65 @@
+package business
+
+import (
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
+)
+
+// Correspondance entre le modèle interne et les contrats de common/events :
+// les consommateurs ne dépendent que du contrat, jamais de models.Commande.
+
+// toOrderCreatedEvent traduit une commande en événement OrderCreated.
+func toOrderCreatedEvent(c models.Commande) events.OrderCreatedEvent {
+	return events.OrderCreatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderCreated),
+		Payload: events.OrderCreatedPayload{
+			OrderID:     c.ID,
+			UserID:      c.UserID,
+			Items:       toOrderItems(c),
+			TotalAmount: c.Amount,
+			Currency:    c.Currency,
+			OrderDate:   c.CreatedAt,
+		},
+	}
+}
+
+// toOrderUpdatedEvent traduit une commande modifiée en événement OrderUpdated.
+func toOrderUpdatedEvent(c models.Commande, updatedAt time.Time) events.OrderUpdatedEvent {
+	return events.OrderUpdatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderUpdated),
+		Payload: events.OrderUpdatedPayload{
+			OrderID:     c.ID,
+			UserID:      c.UserID,
+			Items:       toOrderItems(c),
+			TotalAmount: c.Amount,
+			Currency:    c.Currency,
+			Status:      string(c.Status),
+			OrderDate:   c.CreatedAt,
+			UpdatedAt:   updatedAt,
+		},
+	}
+}
+
+// toOrderCanceledEvent construit l'événement OrderCanceled d'une commande.
+func toOrderCanceledEvent(c models.Commande, reason string, canceledAt time.Time) events.OrderCanceledEvent {
+	return events.OrderCanceledEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderCanceled),
+		Payload: events.OrderCanceledPayload{
+			OrderID:    c.ID,
+			UserID:     c.UserID,
+			CanceledAt: canceledAt,
+			Reason:     reason,
+		},
+	}
+}
+
+// toOrderItems traduit les lignes d'une commande en articles du contrat.
+func toOrderItems(c models.Commande) []events.OrderItem {
+	items := make([]events.OrderItem, len(c.Items))
+	for i, item := range c.Items {
+		items[i] = events.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity, UnitPrice: item.UnitPrice}
+	}
+	return items
+}
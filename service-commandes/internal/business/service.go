@@ -8,9 +8,14 @@ import (
 
 // CommandeService définit les opérations offertes par la couche métier.
 type CommandeService interface {
-	CreateCommande(ctx context.Context, commande models.Commande) error
-	GetAllCommandes(ctx context.Context) ([]models.Commande, error)
+	CreateCommande(ctx context.Context, commande models.Commande) (*models.Commande, error)
+	GetAllCommandes(ctx context.Context, q models.ListQuery) (*models.CommandePage, error)
 	GetCommandeByID(ctx context.Context, id string) (*models.Commande, error)
-	UpdateCommande(ctx context.Context, id string, update models.Commande) error
+	GetUserCommandes(ctx context.Context, userID string, q models.ListQuery) (*models.CommandePage, error)
+	GetUserSummary(ctx context.Context, userID string) (*models.UserSummary, error)
+	UpdateCommande(ctx context.Context, id string, update models.Commande) (*models.Commande, error)
+	CancelCommande(ctx context.Context, id, reason, canceledBy string) (*models.Commande, error)
 	DeleteCommande(ctx context.Context, id string) error
+	ChangeStatus(ctx context.Context, id string, next models.Status) (*models.Commande, error)
+	GetCommandeHistory(ctx context.Context, id string) ([]models.StatusChange, error)
 }
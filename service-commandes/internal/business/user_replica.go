@@ -0,0 +1,98 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/consumer"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/repository"
+)
+
+// ErrUnknownUser est retournée pour une commande dont l'utilisateur n'a
+// jamais été répliqué ou a été supprimé.
+var ErrUnknownUser = errors.New("unknown or deleted user")
+
+var (
+	upsertReplicaUser = repository.UpsertReplicaUser
+	deleteReplicaUser = repository.DeleteReplicaUser
+	getReplicaUser    = repository.GetReplicaUser
+)
+
+// StartUserReplication consomme en arrière-plan, jusqu'à l'annulation du
+// contexte, les événements utilisateurs de la queue USERS_QUEUE
+// (commandes.users par défaut) pour tenir à jour users_replica.
+func StartUserReplication(ctx context.Context) error {
+	queue := os.Getenv("USERS_QUEUE")
+	if queue == "" {
+		queue = "commandes.users"
+	}
+
+	topology, err := messaging.DefaultTopology()
+	if err != nil {
+		return err
+	}
+
+	service := Service{}
+	c := consumer.New(queue)
+	if q, ok := topology.Queue(queue); ok {
+		c.Configure(q)
+	}
+	c.Handle(events.TypeUserCreated, consumer.Decode(service.HandleUserCreated))
+	c.Handle(events.TypeUserUpdated, consumer.Decode(service.HandleUserUpdated))
+	c.Handle(events.TypeUserDeleted, consumer.Decode(service.HandleUserDeleted))
+
+	dedup := consumer.NewSQLDeduplicator(repository.DB())
+	c.Deduplicator = dedup
+	go dedup.RunPurge(ctx, consumer.DefaultDedupRetention, time.Hour)
+
+	go c.Serve(ctx, os.Getenv("RABBITMQ_URL"))
+	return nil
+}
+
+// HandleUserCreated réplique un nouvel utilisateur.
+func (s Service) HandleUserCreated(ctx context.Context, event events.UserCreatedEvent) error {
+	return upsertReplicaUser(ctx, models.User{
+		UserID:    event.Payload.UserID,
+		Username:  event.Payload.Username,
+		Email:     event.Payload.Email,
+		UpdatedAt: event.Payload.CreatedAt,
+	})
+}
+
+// HandleUserUpdated réplique la modification d'un utilisateur.
+func (s Service) HandleUserUpdated(ctx context.Context, event events.UserUpdatedEvent) error {
+	return upsertReplicaUser(ctx, models.User{
+		UserID:    event.Payload.UserID,
+		Username:  event.Payload.Username,
+		Email:     event.Payload.Email,
+		UpdatedAt: event.Payload.UpdatedAt,
+	})
+}
+
+// HandleUserDeleted marque un utilisateur comme supprimé : il ne peut plus
+// passer commande.
+func (s Service) HandleUserDeleted(ctx context.Context, event events.UserDeletedEvent) error {
+	return deleteReplicaUser(ctx, event.Payload.UserID, event.Payload.DeletedAt)
+}
+
+// checkUser vérifie, sur la copie locale, que l'utilisateur existe et n'a
+// pas été supprimé.
+func checkUser(ctx context.Context, userID string) error {
+	u, err := getReplicaUser(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return fmt.Errorf("%w: %s", ErrUnknownUser, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if !u.Active() {
+		return fmt.Errorf("%w: %s", ErrUnknownUser, userID)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package business
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockReplicaUsers simule la table users_replica.
+func mockReplicaUsers(t *testing.T, users ...models.User) {
+	originalGet := getReplicaUser
+	t.Cleanup(func() { getReplicaUser = originalGet })
+
+	getReplicaUser = func(_ context.Context, userID string) (models.User, error) {
+		for _, u := range users {
+			if u.UserID == userID {
+				return u, nil
+			}
+		}
+		return models.User{}, repository.ErrUserNotFound
+	}
+}
+
+func TestCreateCommande_RejectsUnknownOrDeletedUser(t *testing.T) {
+	deletedAt := time.Now().UTC()
+	mockReplicaUsers(t, models.User{UserID: "supprime", DeletedAt: &deletedAt})
+
+	originalInsert := insertCommande
+	defer func() { insertCommande = originalInsert }()
+	insertCommande = func(context.Context, models.Commande, ...outbox.Message) error {
+		t.Fatal("la commande ne doit pas être enregistrée")
+		return nil
+	}
+
+	for _, userID := range []string{"inconnu", "supprime"} {
+		_, err := Service{}.CreateCommande(context.Background(), models.Commande{
+			UserID: userID,
+			Items:  []models.OrderItem{{ProductID: "p-1", Quantity: 1, UnitPrice: 100}},
+		})
+		assert.ErrorIs(t, err, ErrUnknownUser, userID)
+	}
+}
+
+func TestHandleUserEvents_UpdateReplica(t *testing.T) {
+	originalUpsert, originalDelete := upsertReplicaUser, deleteReplicaUser
+	defer func() { upsertReplicaUser, deleteReplicaUser = originalUpsert, originalDelete }()
+
+	var upserted []models.User
+	upsertReplicaUser = func(_ context.Context, u models.User) error {
+		upserted = append(upserted, u)
+		return nil
+	}
+	var deleted []string
+	deleteReplicaUser = func(_ context.Context, userID string, _ time.Time) error {
+		deleted = append(deleted, userID)
+		return nil
+	}
+
+	createdAt := time.Date(2025, 4, 15, 10, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	s := Service{}
+
+	assert.NoError(t, s.HandleUserCreated(context.Background(), events.UserCreatedEvent{
+		Payload: events.UserCreatedPayload{UserID: "u-1", Username: "lahoucine", Email: "l@example.com", CreatedAt: createdAt},
+	}))
+	assert.NoError(t, s.HandleUserUpdated(context.Background(), events.UserUpdatedEvent{
+		Payload: events.UserUpdatedPayload{UserID: "u-1", Username: "lahoucine", Email: "nouveau@example.com", UpdatedAt: updatedAt},
+	}))
+	assert.NoError(t, s.HandleUserDeleted(context.Background(), events.UserDeletedEvent{
+		Payload: events.UserDeletedPayload{UserID: "u-1", DeletedAt: updatedAt},
+	}))
+
+	// l'horodatage de l'événement sert à ignorer les messages en retard
+	assert.Equal(t, []models.User{
+		{UserID: "u-1", Username: "lahoucine", Email: "l@example.com", UpdatedAt: createdAt},
+		{UserID: "u-1", Username: "lahoucine", Email: "nouveau@example.com", UpdatedAt: updatedAt},
+	}, upserted)
+	assert.Equal(t, []string{"u-1"}, deleted)
+}
@@ -1,18 +1,42 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+)
+
+// Commande représente une commande et ses lignes ; Amount est le total
+// calculé à partir des lignes, en unités mineures de Currency (ISO 4217).
 type Commande struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Product   string    `json:"product"`
-	Amount    float64   `json:"amount"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string       `json:"id"`
+	UserID    string       `json:"user_id"`
+	Items     []OrderItem  `json:"items"`
+	Amount    money.Amount `json:"amount"`
+	Currency  string       `json:"currency"`
+	Status    Status       `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+
+	// Renseignés lorsque la commande est annulée. CanceledBy vaut « admin »
+	// ou « client » : le service n'identifie pas plus finement l'auteur.
+	CancelReason string     `json:"cancel_reason,omitempty"`
+	CanceledBy   string     `json:"canceled_by,omitempty"`
+	CanceledAt   *time.Time `json:"canceled_at,omitempty"`
+}
+
+// OrderItem est une ligne de commande ; UnitPrice est dans la devise de la
+// commande.
+type OrderItem struct {
+	ProductID string       `json:"product_id"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice money.Amount `json:"unit_price"`
 }
 
-type CommandeInput struct {
-	UserID  string  `json:"user_id" binding:"required"`
-	Product string  `json:"product" binding:"required"`
-	Amount  float64 `json:"amount" binding:"required"`
+// Total retourne le montant d'une liste de lignes.
+func Total(items []OrderItem) money.Amount {
+	var total money.Amount
+	for _, item := range items {
+		total += money.Amount(item.Quantity) * item.UnitPrice
+	}
+	return total
 }
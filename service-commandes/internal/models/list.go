@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/money"
+)
+
+// Tailles de page de GET /commandes.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// SortField est une colonne de tri des listes de commandes.
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByAmount    SortField = "amount"
+)
+
+// ErrInvalidCursor est retournée pour un curseur illisible ou émis pour un
+// autre tri.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrCurrencyRequired est retournée pour un filtre ou un tri sur le montant
+// sans devise : des unités mineures de devises différentes ne se comparent
+// pas.
+var ErrCurrencyRequired = errors.New("currency is required to filter or sort by amount")
+
+// ListQuery décrit une page de commandes : filtres facultatifs, tri
+// (SortByCreatedAt par défaut) et position après Cursor.
+type ListQuery struct {
+	UserID      string
+	Status      Status
+	Currency    string
+	CreatedFrom *time.Time    // inclus
+	CreatedTo   *time.Time    // exclu
+	MinAmount   *money.Amount // dans Currency
+	MaxAmount   *money.Amount // dans Currency
+
+	Sort   SortField
+	Desc   bool
+	Limit  int
+	Cursor *Cursor
+}
+
+// UsesAmount indique si la requête filtre ou trie sur le montant, ce qui
+// impose une devise.
+func (q ListQuery) UsesAmount() bool {
+	return q.MinAmount != nil || q.MaxAmount != nil || q.Sort == SortByAmount
+}
+
+// Cursor repère la dernière commande d'une page : sa valeur de tri et son
+// ID, qui départage les égalités.
+type Cursor struct {
+	Sort      SortField    `json:"s"`
+	Desc      bool         `json:"d,omitempty"`
+	CreatedAt time.Time    `json:"c,omitempty"`
+	Amount    money.Amount `json:"a,omitempty"`
+	ID        string       `json:"id"`
+}
+
+// CursorAfter retourne le curseur pointant juste après c pour le tri de q.
+func (q ListQuery) CursorAfter(c Commande) Cursor {
+	cursor := Cursor{Sort: q.Sort, Desc: q.Desc, ID: c.ID}
+	switch q.Sort {
+	case SortByAmount:
+		cursor.Amount = c.Amount
+	default:
+		cursor.CreatedAt = c.CreatedAt
+	}
+	return cursor
+}
+
+// Encode sérialise le curseur pour le paramètre ?cursor=.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor relit un curseur produit par Encode et vérifie qu'il
+// correspond au tri demandé.
+func DecodeCursor(s string, sort SortField, desc bool) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// CommandePage est une page de commandes ; NextCursor est vide sur la
+// dernière page.
+type CommandePage struct {
+	Items      []Commande `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// UserSummary résume les commandes non supprimées d'un utilisateur.
+// TotalSpent ignore les commandes annulées ou remboursées et donne un total
+// par devise.
+type UserSummary struct {
+	UserID      string          `json:"user_id"`
+	OrderCount  int             `json:"order_count"`
+	TotalSpent  []CurrencyTotal `json:"total_spent"`
+	LastOrderAt *time.Time      `json:"last_order_at,omitempty"`
+}
+
+// CurrencyTotal est un montant cumulé dans une devise.
+type CurrencyTotal struct {
+	Currency string       `json:"currency"`
+	Amount   money.Amount `json:"amount"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	c := Commande{ID: "c-1", Amount: 4999, CreatedAt: time.Date(2025, 4, 15, 10, 0, 0, 123000, time.UTC)}
+
+	for _, q := range []ListQuery{
+		{Sort: SortByCreatedAt, Desc: true},
+		{Sort: SortByAmount},
+	} {
+		encoded := q.CursorAfter(c).Encode()
+
+		cursor, err := DecodeCursor(encoded, q.Sort, q.Desc)
+		require.NoError(t, err)
+		assert.Equal(t, q.CursorAfter(c), *cursor)
+	}
+}
+
+func TestDecodeCursor_Rejects(t *testing.T) {
+	byAmount := ListQuery{Sort: SortByAmount}.CursorAfter(Commande{ID: "c-1", Amount: 100}).Encode()
+
+	cases := map[string]struct {
+		cursor string
+		sort   SortField
+		desc   bool
+	}{
+		"base64 invalide": {"%%%", SortByCreatedAt, false},
+		"JSON invalide":   {"bm9u", SortByCreatedAt, false},
+		"autre tri":       {byAmount, SortByCreatedAt, false},
+		"autre sens":      {byAmount, SortByAmount, true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeCursor(tc.cursor, tc.sort, tc.desc)
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+		})
+	}
+}
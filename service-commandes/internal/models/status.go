@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status est l'état d'une commande dans son cycle de vie.
+type Status string
+
+const (
+	StatusEnAttente  Status = "en_attente"
+	StatusConfirmee  Status = "confirmee"
+	StatusExpediee   Status = "expediee"
+	StatusLivree     Status = "livree"
+	StatusAnnulee    Status = "annulee"
+	StatusRemboursee Status = "remboursee"
+)
+
+// transitions liste, pour chaque état, les états atteignables :
+//
+//	en_attente → confirmee → expediee → livree
+//	en_attente, confirmee → annulee
+//	confirmee, expediee, livree → remboursee
+//
+// Une commande annulée n'a jamais été payée : elle ne se rembourse pas.
+var transitions = map[Status][]Status{
+	StatusEnAttente: {StatusConfirmee, StatusAnnulee},
+	StatusConfirmee: {StatusExpediee, StatusAnnulee, StatusRemboursee},
+	StatusExpediee:  {StatusLivree, StatusRemboursee},
+	StatusLivree:    {StatusRemboursee},
+}
+
+// ErrInvalidTransition est la cause de toute *TransitionError.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionError décrit un changement d'état interdit.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: %s -> %s", ErrInvalidTransition, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// TransitionTo vérifie que le passage de s à next est autorisé.
+func (s Status) TransitionTo(next Status) error {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return nil
+		}
+	}
+	return &TransitionError{From: s, To: next}
+}
+
+// StatusChange est une entrée de l'historique des états d'une commande.
+// From est vide pour l'état initial.
+type StatusChange struct {
+	From      Status    `json:"from,omitempty"`
+	To        Status    `json:"to"`
+	ChangedAt time.Time `json:"changed_at"`
+}
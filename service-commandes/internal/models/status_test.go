@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from, to Status
+		allowed  bool
+	}{
+		{StatusEnAttente, StatusConfirmee, true},
+		{StatusConfirmee, StatusExpediee, true},
+		{StatusExpediee, StatusLivree, true},
+		{StatusLivree, StatusRemboursee, true},
+		{StatusEnAttente, StatusAnnulee, true},
+		{StatusConfirmee, StatusAnnulee, true},
+		{StatusConfirmee, StatusRemboursee, true},
+		{StatusExpediee, StatusRemboursee, true},
+		{StatusAnnulee, StatusRemboursee, false},
+		{StatusEnAttente, StatusRemboursee, false},
+		{StatusEnAttente, StatusLivree, false},
+		{StatusExpediee, StatusAnnulee, false},
+		{StatusLivree, StatusEnAttente, false},
+		{StatusRemboursee, StatusConfirmee, false},
+		{StatusConfirmee, StatusConfirmee, false},
+	}
+
+	for _, tc := range cases {
+		err := tc.from.TransitionTo(tc.to)
+		if tc.allowed {
+			assert.NoError(t, err, "%s -> %s", tc.from, tc.to)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidTransition, "%s -> %s", tc.from, tc.to)
+		}
+	}
+}
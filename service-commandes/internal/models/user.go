@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// User est la copie locale d'un utilisateur, alimentée par les événements
+// UserCreated, UserUpdated et UserDeleted de service-utilisateurs.
+// UpdatedAt est l'horodatage du dernier événement appliqué.
+type User struct {
+	UserID    string
+	Username  string
+	Email     string
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// Active indique si l'utilisateur peut passer commande.
+func (u User) Active() bool {
+	return u.DeletedAt == nil
+}
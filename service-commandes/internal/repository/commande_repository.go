@@ -1,16 +1,27 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
 )
 
 var db *sql.DB
 
+// ErrNotFound est retournée lorsqu'aucune commande ne correspond à l'ID.
+var ErrNotFound = errors.New("commande not found")
+
 // InitDB initialise la connexion à la base de données PostgreSQL.
 func InitDB() error {
 	var err error
@@ -29,18 +40,134 @@ func InitDB() error {
 	return nil
 }
 
-// InsertCommande insère une commande dans la base.
-func InsertCommande(c models.Commande) error {
-	_, err := db.Exec(`
-		INSERT INTO commandes (id, user_id, product, amount, status, created_at)
+// DB retourne la connexion partagée (utilisée par le relais outbox).
+func DB() *sql.DB {
+	return db
+}
+
+// InsertCommande insère une commande et ses événements dans l'outbox, au sein
+// d'une même transaction.
+func InsertCommande(ctx context.Context, c models.Commande, messages ...outbox.Message) (err error) {
+	ctx, span := tracing.StartDB(ctx, "InsertCommande")
+	defer tracing.End(span, &err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO commandes (id, user_id, amount, currency, status, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
-	`, c.ID, c.UserID, c.Product, c.Amount, c.Status, c.CreatedAt)
-	return err
+	`, c.ID, c.UserID, c.Amount, c.Currency, c.Status, c.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertItems(ctx, tx, c.ID, c.Items); err != nil {
+		return err
+	}
+
+	if err := insertStatusChange(ctx, tx, c.ID, "", c.Status, c.CreatedAt); err != nil {
+		return err
+	}
+
+	if err := outbox.Enqueue(tx, messages...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// commandeColumns liste les colonnes lues par scanCommande.
+const commandeColumns = `id, user_id, amount, currency, status, created_at,
+	COALESCE(cancel_reason, ''), COALESCE(canceled_by, ''), canceled_at`
+
+// scanner est implémenté par *sql.Row et *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCommande(row scanner) (models.Commande, error) {
+	var c models.Commande
+	err := row.Scan(&c.ID, &c.UserID, &c.Amount, &c.Currency, &c.Status, &c.CreatedAt,
+		&c.CancelReason, &c.CanceledBy, &c.CanceledAt)
+	return c, err
+}
+
+// sortColumns associe chaque tri autorisé à sa colonne ; seules ces valeurs
+// sont interpolées dans la requête.
+var sortColumns = map[models.SortField]string{
+	models.SortByCreatedAt: "created_at",
+	models.SortByAmount:    "amount",
 }
 
-// GetAllCommandes retourne toutes les commandes.
-func GetAllCommandes() ([]models.Commande, error) {
-	rows, err := db.Query(`SELECT id, user_id, product, amount, status, created_at FROM commandes`)
+// ListCommandes retourne au plus q.Limit commandes non supprimées filtrées
+// et triées selon q, à partir de q.Cursor (pagination par clé : la page
+// suivante reprend après le couple (valeur de tri, id) du curseur).
+func ListCommandes(ctx context.Context, q models.ListQuery) (_ []models.Commande, err error) {
+	ctx, span := tracing.StartDB(ctx, "ListCommandes")
+	defer tracing.End(span, &err)
+
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("tri inconnu : %q", q.Sort)
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	var args []any
+	where := func(format string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if q.UserID != "" {
+		where("user_id = %s", q.UserID)
+	}
+	if q.Status != "" {
+		where("status = %s", q.Status)
+	}
+	if q.Currency != "" {
+		where("currency = %s", q.Currency)
+	}
+	if q.CreatedFrom != nil {
+		where("created_at >= %s", q.CreatedFrom.UTC())
+	}
+	if q.CreatedTo != nil {
+		where("created_at < %s", q.CreatedTo.UTC())
+	}
+	if q.MinAmount != nil {
+		where("amount >= %s", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		where("amount <= %s", *q.MaxAmount)
+	}
+
+	order, after := "ASC", ">"
+	if q.Desc {
+		order, after = "DESC", "<"
+	}
+	if q.Cursor != nil {
+		var value any = q.Cursor.CreatedAt.UTC()
+		if q.Sort == models.SortByAmount {
+			value = q.Cursor.Amount
+		}
+		where("("+column+", id) "+after+" (%s, %s)", value, q.Cursor.ID)
+	}
+
+	query := `SELECT ` + commandeColumns + ` FROM commandes
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + column + ` ` + order + `, id ` + order
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,37 +175,282 @@ func GetAllCommandes() ([]models.Commande, error) {
 
 	var commandes []models.Commande
 	for rows.Next() {
-		var c models.Commande
-		if err := rows.Scan(&c.ID, &c.UserID, &c.Product, &c.Amount, &c.Status, &c.CreatedAt); err != nil {
+		c, err := scanCommande(rows)
+		if err != nil {
 			return nil, err
 		}
 		commandes = append(commandes, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachItems(ctx, db, commandes); err != nil {
+		return nil, err
+	}
 	return commandes, nil
 }
 
-// GetCommandeByID retourne une commande par ID.
-func GetCommandeByID(id string) (models.Commande, error) {
-	var c models.Commande
-	err := db.QueryRow(`
-		SELECT id, user_id, product, amount, status, created_at
-		FROM commandes WHERE id = $1
-	`, id).Scan(&c.ID, &c.UserID, &c.Product, &c.Amount, &c.Status, &c.CreatedAt)
+// GetCommandeByID retourne une commande non supprimée par ID, ou ErrNotFound.
+func GetCommandeByID(ctx context.Context, id string) (_ models.Commande, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetCommandeByID")
+	defer tracing.End(span, &err)
+
+	c, err := scanCommande(db.QueryRowContext(ctx, `
+		SELECT `+commandeColumns+`
+		FROM commandes WHERE id = $1 AND deleted_at IS NULL
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, ErrNotFound
+	}
+	if err != nil {
+		return c, err
+	}
+
+	c.Items, err = getItems(ctx, db, c.ID)
 	return c, err
 }
 
-// UpdateCommande met à jour une commande.
-func UpdateCommande(c models.Commande) error {
-	_, err := db.Exec(`
+// UpdateFunc reçoit la commande verrouillée et retourne la version à
+// enregistrer ainsi que les événements à écrire dans l'outbox.
+type UpdateFunc func(current models.Commande) (models.Commande, []outbox.Message, error)
+
+// UpdateCommande verrouille la commande (SELECT ... FOR UPDATE), applique
+// apply puis enregistre le résultat, le changement d'état éventuel dans
+// l'historique et les événements dans l'outbox, au sein d'une même
+// transaction. Retourne la version enregistrée, ou ErrNotFound.
+func UpdateCommande(ctx context.Context, id string, apply UpdateFunc) (_ models.Commande, err error) {
+	ctx, span := tracing.StartDB(ctx, "UpdateCommande")
+	defer tracing.End(span, &err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Commande{}, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanCommande(tx.QueryRowContext(ctx, `
+		SELECT `+commandeColumns+`
+		FROM commandes WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Commande{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Commande{}, err
+	}
+	if current.Items, err = getItems(ctx, tx, id); err != nil {
+		return models.Commande{}, err
+	}
+
+	updated, messages, err := apply(current)
+	if err != nil {
+		return models.Commande{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
 		UPDATE commandes
-		SET user_id = $1, product = $2, amount = $3, status = $4
-		WHERE id = $5
-	`, c.UserID, c.Product, c.Amount, c.Status, c.ID)
-	return err
+		SET amount = $1, status = $2,
+			cancel_reason = NULLIF($3, ''), canceled_by = NULLIF($4, ''), canceled_at = $5
+		WHERE id = $6
+	`, updated.Amount, updated.Status,
+		updated.CancelReason, updated.CanceledBy, updated.CanceledAt, id)
+	if err != nil {
+		return models.Commande{}, err
+	}
+
+	if !slices.Equal(updated.Items, current.Items) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM order_items WHERE commande_id = $1`, id); err != nil {
+			return models.Commande{}, err
+		}
+		if err := insertItems(ctx, tx, id, updated.Items); err != nil {
+			return models.Commande{}, err
+		}
+	}
+
+	if updated.Status != current.Status {
+		if err := insertStatusChange(ctx, tx, id, current.Status, updated.Status, time.Now().UTC()); err != nil {
+			return models.Commande{}, err
+		}
+	}
+
+	if err := outbox.Enqueue(tx, messages...); err != nil {
+		return models.Commande{}, err
+	}
+	return updated, tx.Commit()
+}
+
+// GetStatusHistory retourne l'historique des états d'une commande, du plus
+// ancien au plus récent.
+func GetStatusHistory(ctx context.Context, id string) (_ []models.StatusChange, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetStatusHistory")
+	defer tracing.End(span, &err)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(from_status, ''), to_status, changed_at
+		FROM commande_status_history
+		WHERE commande_id = $1
+		ORDER BY changed_at, id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.StatusChange{}
+	for rows.Next() {
+		var change models.StatusChange
+		if err := rows.Scan(&change.From, &change.To, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// GetUserSummary calcule le résumé des commandes d'un utilisateur.
+func GetUserSummary(ctx context.Context, userID string) (_ models.UserSummary, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetUserSummary")
+	defer tracing.End(span, &err)
+
+	summary := models.UserSummary{UserID: userID, TotalSpent: []models.CurrencyTotal{}}
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MAX(created_at)
+		FROM commandes
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`, userID).Scan(&summary.OrderCount, &summary.LastOrderAt)
+	if err != nil {
+		return summary, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT currency, SUM(amount)
+		FROM commandes
+		WHERE user_id = $1 AND deleted_at IS NULL AND status NOT IN ($2, $3)
+		GROUP BY currency
+		ORDER BY currency
+	`, userID, models.StatusAnnulee, models.StatusRemboursee)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var total models.CurrencyTotal
+		if err := rows.Scan(&total.Currency, &total.Amount); err != nil {
+			return summary, err
+		}
+		summary.TotalSpent = append(summary.TotalSpent, total)
+	}
+	return summary, rows.Err()
+}
+
+// querier est implémenté par *sql.DB et *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// insertItems enregistre les lignes d'une commande, numérotées dans l'ordre.
+func insertItems(ctx context.Context, tx *sql.Tx, id string, items []models.OrderItem) error {
+	for i, item := range items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_items (commande_id, line, product_id, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, i+1, item.ProductID, item.Quantity, item.UnitPrice)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// DeleteCommande supprime une commande.
-func DeleteCommande(id string) error {
-	_, err := db.Exec(`DELETE FROM commandes WHERE id = $1`, id)
+// getItems retourne les lignes d'une commande.
+func getItems(ctx context.Context, q querier, id string) ([]models.OrderItem, error) {
+	byCommande, err := queryItems(ctx, q, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return byCommande[id], nil
+}
+
+// attachItems charge en une requête les lignes de plusieurs commandes.
+func attachItems(ctx context.Context, q querier, commandes []models.Commande) error {
+	if len(commandes) == 0 {
+		return nil
+	}
+	ids := make([]string, len(commandes))
+	for i, c := range commandes {
+		ids[i] = c.ID
+	}
+
+	byCommande, err := queryItems(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	for i := range commandes {
+		commandes[i].Items = byCommande[commandes[i].ID]
+	}
+	return nil
+}
+
+func queryItems(ctx context.Context, q querier, ids []string) (map[string][]models.OrderItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT commande_id, product_id, quantity, unit_price
+		FROM order_items
+		WHERE commande_id = ANY($1)
+		ORDER BY commande_id, line
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byCommande := make(map[string][]models.OrderItem)
+	for rows.Next() {
+		var id string
+		var item models.OrderItem
+		if err := rows.Scan(&id, &item.ProductID, &item.Quantity, &item.UnitPrice); err != nil {
+			return nil, err
+		}
+		byCommande[id] = append(byCommande[id], item)
+	}
+	return byCommande, rows.Err()
+}
+
+// insertStatusChange ajoute une entrée à l'historique des états.
+func insertStatusChange(ctx context.Context, tx *sql.Tx, id string, from, to models.Status, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO commande_status_history (commande_id, from_status, to_status, changed_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4)
+	`, id, from, to, at)
 	return err
 }
+
+// SoftDeleteCommande masque une commande (deleted_at) sans effacer la ligne
+// ni son historique, ou retourne ErrNotFound.
+func SoftDeleteCommande(ctx context.Context, id string) (err error) {
+	ctx, span := tracing.StartDB(ctx, "SoftDeleteCommande")
+	defer tracing.End(span, &err)
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE commandes SET deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// expectOneRow retourne ErrNotFound si la requête n'a touché aucune ligne.
+func expectOneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/models"
+)
+
+// ErrUserNotFound est retournée lorsqu'aucun utilisateur n'a été répliqué
+// pour l'ID demandé.
+var ErrUserNotFound = errors.New("user not found")
+
+// UpsertReplicaUser applique un UserCreated ou un UserUpdated. Un événement
+// plus ancien que le dernier appliqué est ignoré, et un utilisateur supprimé
+// le reste.
+func UpsertReplicaUser(ctx context.Context, u models.User) (err error) {
+	ctx, span := tracing.StartDB(ctx, "UpsertReplicaUser")
+	defer tracing.End(span, &err)
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO users_replica (user_id, username, email, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET username = EXCLUDED.username, email = EXCLUDED.email, updated_at = EXCLUDED.updated_at
+		WHERE users_replica.updated_at < EXCLUDED.updated_at AND users_replica.deleted_at IS NULL
+	`, u.UserID, u.Username, u.Email, u.UpdatedAt)
+	return err
+}
+
+// DeleteReplicaUser applique un UserDeleted. La ligne est conservée comme
+// pierre tombale, y compris pour un utilisateur encore inconnu, afin qu'un
+// UserCreated reçu en retard ne le fasse pas réapparaître.
+func DeleteReplicaUser(ctx context.Context, userID string, deletedAt time.Time) (err error) {
+	ctx, span := tracing.StartDB(ctx, "DeleteReplicaUser")
+	defer tracing.End(span, &err)
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO users_replica (user_id, username, email, updated_at, deleted_at)
+		VALUES ($1, '', '', $2, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET updated_at = EXCLUDED.updated_at, deleted_at = EXCLUDED.deleted_at
+		WHERE users_replica.deleted_at IS NULL
+	`, userID, deletedAt)
+	return err
+}
+
+// GetReplicaUser retourne la copie locale d'un utilisateur, supprimé ou
+// non, ou ErrUserNotFound.
+func GetReplicaUser(ctx context.Context, userID string) (_ models.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetReplicaUser")
+	defer tracing.End(span, &err)
+
+	var u models.User
+	err = db.QueryRowContext(ctx, `
+		SELECT user_id, username, email, updated_at, deleted_at
+		FROM users_replica WHERE user_id = $1
+	`, userID).Scan(&u.UserID, &u.Username, &u.Email, &u.UpdatedAt, &u.DeletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrUserNotFound
+	}
+	return u, err
+}
@@ -1,7 +1,12 @@
 package server
 
 import (
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/idempotency"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/api"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-commandes/internal/business"
 )
@@ -9,17 +14,34 @@ import (
 // SetupRouter configure les routes HTTP pour le service commandes.
 func SetupRouter() *gin.Engine {
 	router := gin.Default()
+	// les handlers passent *gin.Context aux services comme context.Context
+	router.ContextWithFallback = true
+	router.Use(correlation.Middleware(), tracing.Middleware())
 
 	router.GET("/health", api.HealthHandler)
 
 	handler := api.NewHandler(business.Service{}) // instance réelle ici
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	idempotent := idempotency.Middleware(business.IdempotencyStore(), idempotency.RetentionFromEnv())
 
 	// Routes REST
-	router.POST("/commandes", handler.CreateCommandeHandler)
+	router.POST("/commandes", idempotent, handler.CreateCommandeHandler)
 	router.GET("/commandes", handler.GetAllCommandesHandler)
 	router.GET("/commandes/:id", handler.GetCommandeByIDHandler)
 	router.PUT("/commandes/:id", handler.UpdateCommandeHandler)
-	router.DELETE("/commandes/:id", handler.DeleteCommandeHandler)
+	router.DELETE("/commandes/:id", api.AdminOnly(adminToken), handler.DeleteCommandeHandler)
+
+	// Cycle de vie
+	router.POST("/commandes/:id/confirm", handler.ConfirmCommandeHandler)
+	router.POST("/commandes/:id/ship", handler.ShipCommandeHandler)
+	router.POST("/commandes/:id/deliver", handler.DeliverCommandeHandler)
+	router.POST("/commandes/:id/refund", handler.RefundCommandeHandler)
+	router.POST("/commandes/:id/cancel", api.IdentifyActor(adminToken), handler.CancelCommandeHandler)
+	router.GET("/commandes/:id/history", handler.GetCommandeHistoryHandler)
+
+	// Commandes d'un utilisateur
+	router.GET("/users/:id/commandes", handler.GetUserCommandesHandler)
+	router.GET("/users/:id/commandes/summary", handler.GetUserSummaryHandler)
 
 	return router
 }
@@ -0,0 +1,28 @@
+-- Historique des états des commandes (voir initdb/init.sql).
+--
+-- Chaque commande existante reçoit une entrée initiale : son état actuel,
+-- daté de sa création. Avant le cycle de vie, PUT /commandes/:id acceptait
+-- n'importe quel statut : les commandes dont l'état n'appartient pas au cycle
+-- (en_attente, confirmee, expediee, livree, annulee, remboursee) sont à
+-- corriger à la main. La migration peut être rejouée sans effet :
+--
+--   psql "$POSTGRES_CONN" -f migrations/002_commande_status_history.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS commande_status_history (
+  id BIGSERIAL PRIMARY KEY,
+  commande_id UUID NOT NULL REFERENCES commandes (id) ON DELETE CASCADE,
+  from_status TEXT,
+  to_status TEXT NOT NULL,
+  changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS commande_status_history_commande_idx ON commande_status_history (commande_id, changed_at);
+
+INSERT INTO commande_status_history (commande_id, from_status, to_status, changed_at)
+SELECT c.id, NULL, c.status, c.created_at
+FROM commandes c
+WHERE NOT EXISTS (SELECT 1 FROM commande_status_history h WHERE h.commande_id = c.id);
+
+COMMIT;
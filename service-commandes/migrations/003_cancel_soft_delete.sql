@@ -0,0 +1,11 @@
+-- Annulation motivée et suppression logique des commandes (voir
+-- initdb/init.sql). Les index partiels « WHERE deleted_at IS NULL » de
+-- GET /commandes sont créés par 006_commandes_list_indexes.sql.
+--
+--   psql "$POSTGRES_CONN" -f migrations/003_cancel_soft_delete.sql
+
+ALTER TABLE commandes
+  ADD COLUMN IF NOT EXISTS cancel_reason TEXT,
+  ADD COLUMN IF NOT EXISTS canceled_by TEXT,
+  ADD COLUMN IF NOT EXISTS canceled_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,66 @@
+-- Lignes de commande (voir initdb/init.sql).
+--
+-- Le produit et le montant de chaque commande existante deviennent sa ligne
+-- unique (quantité 1), puis la colonne product est supprimée. Les prix
+-- restent en FLOAT : 005_money_minor_units.sql les convertit ensuite.
+--
+-- Les montants existants n'avaient aucune contrainte : la contrainte
+-- unit_price >= 0 est ajoutée NOT VALID (elle s'applique aux nouvelles
+-- lignes) et n'est validée que si aucune ligne reprise ne la viole. Sinon,
+-- les commandes en cause sont signalées (NOTICE) ; une fois corrigées,
+-- rejouer la migration valide la contrainte. La migration peut être rejouée
+-- sans effet :
+--
+--   psql "$POSTGRES_CONN" -f migrations/004_order_items.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS order_items (
+  commande_id UUID NOT NULL REFERENCES commandes (id) ON DELETE CASCADE,
+  line INT NOT NULL,
+  product_id TEXT NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  unit_price FLOAT NOT NULL,
+  PRIMARY KEY (commande_id, line)
+);
+
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns
+             WHERE table_name = 'commandes' AND column_name = 'product') THEN
+    INSERT INTO order_items (commande_id, line, product_id, quantity, unit_price)
+    SELECT c.id, 1, c.product, 1, c.amount
+    FROM commandes c
+    WHERE NOT EXISTS (SELECT 1 FROM order_items i WHERE i.commande_id = c.id);
+
+    ALTER TABLE commandes DROP COLUMN product;
+  END IF;
+
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint
+                 WHERE conrelid = 'order_items'::regclass AND conname = 'order_items_unit_price_check') THEN
+    ALTER TABLE order_items ADD CONSTRAINT order_items_unit_price_check CHECK (unit_price >= 0) NOT VALID;
+  END IF;
+END $$;
+
+DO $$
+DECLARE
+  invalid TEXT;
+BEGIN
+  -- après 005_money_minor_units.sql, c'est elle qui valide la contrainte
+  IF EXISTS (SELECT 1 FROM pg_constraint
+             WHERE conrelid = 'order_items'::regclass
+               AND conname = 'order_items_unit_price_check' AND NOT convalidated)
+     AND (SELECT data_type FROM information_schema.columns
+          WHERE table_name = 'order_items' AND column_name = 'unit_price') = 'double precision' THEN
+    SELECT string_agg(DISTINCT commande_id::TEXT, ', ') INTO invalid
+    FROM order_items WHERE unit_price < 0;
+
+    IF invalid IS NULL THEN
+      ALTER TABLE order_items VALIDATE CONSTRAINT order_items_unit_price_check;
+    ELSE
+      RAISE NOTICE 'order_items_unit_price_check non validée, prix négatifs pour les commandes : %', invalid;
+    END IF;
+  END IF;
+END $$;
+
+COMMIT;
@@ -0,0 +1,69 @@
+-- Montants en unités mineures (centimes) et devise ISO 4217.
+--
+-- initdb/init.sql ne s'exécute que sur une base vide : cette migration met à
+-- niveau une base existante, dont tous les montants FLOAT sont en EUR. Elle
+-- suppose les migrations 001 à 004 appliquées (order_items en particulier).
+--
+-- Les anciens montants n'avaient aucune contrainte, et un montant positif
+-- inférieur à 0,005 devient 0 centime : les contraintes amount > 0 et
+-- unit_price > 0 sont ajoutées NOT VALID (elles s'appliquent aux nouvelles
+-- lignes) et ne sont validées que si aucune ligne convertie ne les viole.
+-- Sinon, les commandes en cause sont signalées (NOTICE) ; une fois
+-- corrigées, rejouer la migration valide les contraintes. La migration peut
+-- être rejouée sans effet :
+--
+--   psql "$POSTGRES_CONN" -f migrations/005_money_minor_units.sql
+
+BEGIN;
+
+ALTER TABLE commandes ADD COLUMN IF NOT EXISTS currency CHAR(3);
+UPDATE commandes SET currency = 'EUR' WHERE currency IS NULL;
+ALTER TABLE commandes ALTER COLUMN currency SET NOT NULL;
+
+DO $$
+BEGIN
+  IF (SELECT data_type FROM information_schema.columns
+      WHERE table_name = 'commandes' AND column_name = 'amount') = 'double precision' THEN
+    ALTER TABLE commandes ALTER COLUMN amount TYPE BIGINT USING round(amount * 100)::BIGINT;
+    ALTER TABLE commandes ADD CONSTRAINT commandes_amount_check CHECK (amount > 0) NOT VALID;
+  END IF;
+
+  IF (SELECT data_type FROM information_schema.columns
+      WHERE table_name = 'order_items' AND column_name = 'unit_price') = 'double precision' THEN
+    ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_unit_price_check;
+    ALTER TABLE order_items ALTER COLUMN unit_price TYPE BIGINT USING round(unit_price * 100)::BIGINT;
+    ALTER TABLE order_items ADD CONSTRAINT order_items_unit_price_check CHECK (unit_price > 0) NOT VALID;
+  END IF;
+END $$;
+
+DO $$
+DECLARE
+  invalid TEXT;
+BEGIN
+  IF EXISTS (SELECT 1 FROM pg_constraint
+             WHERE conrelid = 'commandes'::regclass
+               AND conname = 'commandes_amount_check' AND NOT convalidated) THEN
+    SELECT string_agg(id::TEXT, ', ') INTO invalid FROM commandes WHERE amount <= 0;
+
+    IF invalid IS NULL THEN
+      ALTER TABLE commandes VALIDATE CONSTRAINT commandes_amount_check;
+    ELSE
+      RAISE NOTICE 'commandes_amount_check non validée, montants nuls ou négatifs pour les commandes : %', invalid;
+    END IF;
+  END IF;
+
+  IF EXISTS (SELECT 1 FROM pg_constraint
+             WHERE conrelid = 'order_items'::regclass
+               AND conname = 'order_items_unit_price_check' AND NOT convalidated) THEN
+    SELECT string_agg(DISTINCT commande_id::TEXT, ', ') INTO invalid
+    FROM order_items WHERE unit_price <= 0;
+
+    IF invalid IS NULL THEN
+      ALTER TABLE order_items VALIDATE CONSTRAINT order_items_unit_price_check;
+    ELSE
+      RAISE NOTICE 'order_items_unit_price_check non validée, prix nuls ou négatifs pour les commandes : %', invalid;
+    END IF;
+  END IF;
+END $$;
+
+COMMIT;
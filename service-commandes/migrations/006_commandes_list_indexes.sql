@@ -0,0 +1,8 @@
+-- Index de la pagination de GET /commandes (voir initdb/init.sql).
+--
+--   psql "$POSTGRES_CONN" -f migrations/006_commandes_list_indexes.sql
+
+CREATE INDEX IF NOT EXISTS commandes_created_idx ON commandes (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_amount_idx ON commandes (currency, amount, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_user_created_idx ON commandes (user_id, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS commandes_status_created_idx ON commandes (status, created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,15 @@
+-- Copie locale des utilisateurs (voir initdb/init.sql).
+--
+--   psql "$POSTGRES_CONN" -f migrations/007_users_replica.sql
+--
+-- La table est remplie par les événements reçus après le déploiement : les
+-- utilisateurs créés avant sont copiés depuis la base de service-utilisateurs
+-- par 007_users_replica_backfill.sh, qui décrit l'ordre de déploiement.
+
+CREATE TABLE IF NOT EXISTS users_replica (
+  user_id UUID PRIMARY KEY,
+  username TEXT NOT NULL,
+  email TEXT NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  deleted_at TIMESTAMP
+);
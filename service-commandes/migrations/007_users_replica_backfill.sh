@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+# Reprise de users_replica depuis la base de service-utilisateurs, à lancer
+# une fois après 007_users_replica.sql (ordre de déploiement dans ce fichier).
+#
+#   USERS_CONN=postgres://.../utilisateurs_db POSTGRES_CONN=postgres://.../commandes_db \
+#     bash migrations/007_users_replica_backfill.sh
+#
+# Les utilisateurs sont copiés directement plutôt que republiés en UserCreated :
+# le Service Notifications leur renverrait un message de bienvenue.
+#
+# Ordre de déploiement :
+#   1. démarrer un service portant la topologie à jour (service-utilisateurs par
+#      exemple) : la queue commandes.users est déclarée et retient dès lors les
+#      événements utilisateurs ;
+#   2. appliquer 007_users_replica.sql sur la base commandes ;
+#   3. lancer ce script ;
+#   4. déployer service-commandes, qui rejoue les événements retenus.
+#
+# Une ligne déjà présente (événement reçu ou pierre tombale) n'est pas écrasée :
+# le script peut être relancé sans effet.
+
+set -euo pipefail
+
+: "${USERS_CONN:?USERS_CONN doit pointer vers la base utilisateurs}"
+: "${POSTGRES_CONN:?POSTGRES_CONN doit pointer vers la base commandes}"
+
+psql "$USERS_CONN" -v ON_ERROR_STOP=1 \
+  -c "\copy (SELECT id, username, email, created_at FROM users) TO STDOUT" |
+psql "$POSTGRES_CONN" -v ON_ERROR_STOP=1 \
+  -c "CREATE TEMP TABLE users_import (user_id UUID, username TEXT, email TEXT, updated_at TIMESTAMP)" \
+  -c "\copy users_import FROM pstdin" \
+  -c "INSERT INTO users_replica (user_id, username, email, updated_at)
+      SELECT user_id, username, email, updated_at FROM users_import
+      ON CONFLICT (user_id) DO NOTHING"
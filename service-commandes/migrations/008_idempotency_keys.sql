@@ -0,0 +1,16 @@
+-- Clés Idempotency-Key des POST (voir initdb/init.sql).
+--
+--   psql "$POSTGRES_CONN" -f migrations/008_idempotency_keys.sql
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  key TEXT PRIMARY KEY,
+  fingerprint TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP,
+  status_code INT,
+  content_type TEXT,
+  body BYTEA,
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_idx ON idempotency_keys (created_at);
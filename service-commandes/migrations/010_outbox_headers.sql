@@ -0,0 +1,5 @@
+-- Headers AMQP des messages de l'outbox : correlation et causation IDs.
+--
+--   psql "$POSTGRES_CONN" -f migrations/010_outbox_headers.sql
+
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS headers JSONB;
@@ -9,26 +9,49 @@ import (
 	"testing"
 
 	_ "github.com/lib/pq"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 )
 
+type itemResp struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"unit_price"`
+}
+
 type commandeResp struct {
-	ID        string  `json:"id"`
-	UserID    string  `json:"user_id"`
-	Product   string  `json:"product"`
-	Amount    float64 `json:"amount"`
-	Status    string  `json:"status"`
-	CreatedAt string  `json:"created_at"`
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Items     []itemResp `json:"items"`
+	Amount    int64      `json:"amount"`
+	Currency  string     `json:"currency"`
+	Status    string     `json:"status"`
+	CreatedAt string     `json:"created_at"`
 }
 
 func TestCreateCommandeIntegration(t *testing.T) {
 	_ = godotenv.Load("../.env")
 
+	db, err := sql.Open("postgres", os.Getenv("POSTGRES_CONN"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// l'utilisateur doit être connu de la copie locale (sans passer par
+	// service-utilisateurs dans ce test)
+	_, err = db.Exec(`
+		INSERT INTO users_replica (user_id, username, email, updated_at)
+		VALUES ($1, 'integration', 'integration@example.com', NOW())
+		ON CONFLICT (user_id) DO NOTHING
+	`, "123e4567-e89b-12d3-a456-426614174000")
+	assert.NoError(t, err)
+
 	payload := map[string]interface{}{
 		"user_id": "123e4567-e89b-12d3-a456-426614174000", // UUID valide
-		"product": "Test integration",
-		"amount":  99.99,
+		"items": []map[string]interface{}{
+			{"product_id": "test-integration", "quantity": 2, "unit_price": 4000},
+			{"product_id": "frais-de-port", "quantity": 1, "unit_price": 1999},
+		},
 	}
 	body, _ := json.Marshal(payload)
 
@@ -42,18 +65,88 @@ func TestCreateCommandeIntegration(t *testing.T) {
 	err = json.NewDecoder(resp.Body).Decode(&commande)
 	assert.NoError(t, err)
 
-	assert.Equal(t, "Test integration", commande.Product)
-	assert.Equal(t, 99.99, commande.Amount)
+	assert.Len(t, commande.Items, 2)
+	assert.Equal(t, int64(9999), commande.Amount)
+	assert.Equal(t, "EUR", commande.Currency)
 	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", commande.UserID)
 	assert.Equal(t, "en_attente", commande.Status)
 	assert.NotEmpty(t, commande.ID)
 
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM commandes WHERE id = $1", commande.ID).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	err = db.QueryRow("SELECT COUNT(*) FROM order_items WHERE commande_id = $1", commande.ID).Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestCreateCommandeIntegration_UnknownUser(t *testing.T) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id": "99999999-9999-9999-9999-999999999999",
+		"items":   []map[string]interface{}{{"product_id": "test-integration", "quantity": 1, "unit_price": 100}},
+	})
+
+	resp, err := http.Post("http://service-commandes:8082/commandes", "application/json", bytes.NewBuffer(body))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestCreateCommandeIntegration_IdempotencyKey(t *testing.T) {
+	_ = godotenv.Load("../.env")
+
 	db, err := sql.Open("postgres", os.Getenv("POSTGRES_CONN"))
 	assert.NoError(t, err)
 	defer db.Close()
 
+	_, err = db.Exec(`
+		INSERT INTO users_replica (user_id, username, email, updated_at)
+		VALUES ($1, 'integration', 'integration@example.com', NOW())
+		ON CONFLICT (user_id) DO NOTHING
+	`, "123e4567-e89b-12d3-a456-426614174000")
+	assert.NoError(t, err)
+
+	key := uuid.New().String()
+	post := func(unitPrice int) *http.Response {
+		body, _ := json.Marshal(map[string]interface{}{
+			"user_id": "123e4567-e89b-12d3-a456-426614174000",
+			"items":   []map[string]interface{}{{"product_id": "test-idempotency", "quantity": 1, "unit_price": unitPrice}},
+		})
+		req, _ := http.NewRequest(http.MethodPost, "http://service-commandes:8082/commandes", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	var first, second commandeResp
+	resp := post(500)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+	resp.Body.Close()
+
+	// renvoi après un timeout : même réponse, aucune nouvelle commande
+	resp = post(500)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&second))
+	resp.Body.Close()
+	assert.Equal(t, first.ID, second.ID)
+
+	// même clé, autre corps
+	resp = post(600)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
 	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM commandes WHERE id = $1", commande.ID).Scan(&count)
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM commandes c JOIN order_items i ON i.commande_id = c.id
+		WHERE i.product_id = 'test-idempotency'
+		  AND c.created_at >= (SELECT created_at FROM commandes WHERE id = $1)
+	`, first.ID).Scan(&count)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, count)
 }
@@ -1,9 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/consumer"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/dlq"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/business"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/repository"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/server"
+	"github.com/joho/godotenv"
 )
 
 func main() {
-	fmt.Println("Service Notifications lancé avec succès 🚀")
+	// Charger les variables d'environnement
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("Aucun fichier .env trouvé, on continue avec les variables système...")
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "service-notifications")
+	if err != nil {
+		log.Fatalf("Échec d'initialisation du tracing : %v", err)
+	}
+
+	if err := repository.InitDB(); err != nil {
+		log.Fatalf("Échec d'initialisation de la base de données : %v", err)
+	}
+
+	publisher, err := messaging.Open(context.Background(), os.Getenv("RABBITMQ_URL"))
+	if err != nil {
+		log.Fatalf("Échec de déclaration de la topologie RabbitMQ : %v", err)
+	}
+	defer publisher.Close()
+	business.UsePublisher(publisher)
+
+	queue := os.Getenv("NOTIFICATIONS_QUEUE")
+	if queue == "" {
+		queue = "notifications" // fallback pour le service notifications
+	}
+
+	service := business.Service{}
+
+	topology, err := messaging.DefaultTopology()
+	if err != nil {
+		log.Fatalf("Topologie RabbitMQ invalide : %v", err)
+	}
+
+	c := consumer.New(queue)
+	if q, ok := topology.Queue(queue); ok {
+		c.Configure(q)
+	}
+	c.Handle(events.TypeUserCreated, consumer.Decode(service.HandleUserCreated))
+	c.Handle(events.TypeUserUpdated, consumer.Decode(service.HandleUserUpdated))
+	c.Handle(events.TypeUserDeleted, consumer.Decode(service.HandleUserDeleted))
+	c.Handle(events.TypeOrderCreated, consumer.Decode(service.HandleOrderCreated))
+	c.Handle(events.TypeOrderUpdated, consumer.Decode(service.HandleOrderUpdated))
+	c.Handle(events.TypeOrderCanceled, consumer.Decode(service.HandleOrderCanceled))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Une notification par événement, même si RabbitMQ le redélivre.
+	dedup := consumer.NewSQLDeduplicator(repository.DB())
+	c.Deduplicator = dedup
+	go dedup.RunPurge(ctx, consumer.DefaultDedupRetention, time.Hour)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8083" // fallback pour le service notifications
+	}
+
+	// API d'administration des dead-letter queues
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: server.SetupRouter(dlq.NewAdmin(os.Getenv("RABBITMQ_URL"), topology)),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Échec du serveur HTTP : %v", err)
+			stop()
+		}
+	}()
+
+	fmt.Println("Démarrage du service notifications sur la queue", queue, "et le port", port)
+
+	c.Serve(ctx, os.Getenv("RABBITMQ_URL"))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdownCtx)
+	_ = shutdownTracing(shutdownCtx)
+
+	log.Println("Arrêt du service notifications")
 }
@@ -0,0 +1,21 @@
+-- Une notification par événement consommé (event_id) : un événement rejoué
+-- après un échec n'est pas notifié deux fois.
+CREATE TABLE IF NOT EXISTS notifications (
+  id UUID PRIMARY KEY,
+  event_id TEXT UNIQUE,
+  user_id UUID NOT NULL,
+  event_type TEXT NOT NULL,
+  message TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+-- Événements déjà traités par chaque queue consommée : un message redélivré
+-- par RabbitMQ n'est traité qu'une fois.
+CREATE TABLE IF NOT EXISTS processed_messages (
+  consumer_group TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  processed_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (consumer_group, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS processed_messages_processed_idx ON processed_messages (processed_at);
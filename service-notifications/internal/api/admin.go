@@ -0,0 +1,29 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader porte le jeton des routes d'administration.
+const AdminTokenHeader = "X-Admin-Token"
+
+// HealthHandler traite GET /health
+func HealthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// AdminOnly réserve une route aux détenteurs du jeton d'administration
+// (variable ADMIN_TOKEN). Sans jeton configuré, la route est fermée.
+func AdminOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(AdminTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
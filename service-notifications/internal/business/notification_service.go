@@ -0,0 +1,122 @@
+package business
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/models"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Service est l’implémentation concrète de l’interface NotificationService.
+type Service struct{}
+
+var insertNotification = repository.InsertNotification
+
+// HandleUserCreated souhaite la bienvenue au nouvel utilisateur.
+func (s Service) HandleUserCreated(ctx context.Context, event events.UserCreatedEvent) error {
+	message := fmt.Sprintf("Bienvenue %s ! Votre compte (%s) a bien été créé.", event.Payload.Username, event.Payload.Email)
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, message)
+}
+
+// HandleUserUpdated informe l'utilisateur de la modification de son profil.
+func (s Service) HandleUserUpdated(ctx context.Context, event events.UserUpdatedEvent) error {
+	message := fmt.Sprintf("Bonjour %s, votre profil a été mis à jour (e-mail : %s).", event.Payload.Username, event.Payload.Email)
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, message)
+}
+
+// HandleUserDeleted confirme la suppression du compte.
+func (s Service) HandleUserDeleted(ctx context.Context, event events.UserDeletedEvent) error {
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, "Votre compte a été supprimé.")
+}
+
+// HandleOrderCreated confirme la prise en compte d'une commande.
+func (s Service) HandleOrderCreated(ctx context.Context, event events.OrderCreatedEvent) error {
+	message := fmt.Sprintf("Votre commande %s d'un montant de %s a bien été enregistrée.", event.Payload.OrderID, event.Payload.TotalAmount.Format(event.Payload.Currency))
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, message)
+}
+
+// statusCanceled est le statut d'une commande annulée dans OrderUpdated.
+const statusCanceled = "annulee"
+
+// HandleOrderUpdated informe le client de la modification de sa commande.
+// Une annulation émet aussi OrderCanceled, qui porte la raison : seul ce
+// dernier donne lieu à une notification.
+func (s Service) HandleOrderUpdated(ctx context.Context, event events.OrderUpdatedEvent) error {
+	if event.Payload.Status == statusCanceled {
+		return nil
+	}
+	message := fmt.Sprintf("Votre commande %s a été mise à jour (montant : %s).", event.Payload.OrderID, event.Payload.TotalAmount.Format(event.Payload.Currency))
+	if event.Payload.Status != "" {
+		message = fmt.Sprintf("Votre commande %s est maintenant « %s ».", event.Payload.OrderID, event.Payload.Status)
+	}
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, message)
+}
+
+// HandleOrderCanceled informe le client de l'annulation et de sa raison.
+func (s Service) HandleOrderCanceled(ctx context.Context, event events.OrderCanceledEvent) error {
+	message := fmt.Sprintf("Votre commande %s a été annulée : %s.", event.Payload.OrderID, event.Payload.Reason)
+	return s.notify(ctx, event.EventID, event.Payload.UserID, event.EventType, message)
+}
+
+// notify enregistre la notification, l'envoie puis publie NotificationTriggered.
+// L'enregistrement, unique par eventID, a lieu dans la transaction de
+// déduplication : un échec d'envoi l'annule et l'événement est rejoué. Une
+// fois l'utilisateur notifié, l'événement n'échoue plus : le rejouer
+// renverrait la notification.
+func (s Service) notify(ctx context.Context, eventID, userID, eventType, message string) error {
+	notification := models.Notification{
+		ID:        uuid.New().String(),
+		EventID:   eventID,
+		UserID:    userID,
+		EventType: eventType,
+		Message:   message,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	inserted, err := insertNotification(ctx, notification)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		log.Printf("[Notification] Événement %s déjà notifié, ignoré", eventID)
+		return nil
+	}
+	if err := sendNotification(notification.UserID, notification.Message); err != nil {
+		return err
+	}
+	_ = publishNotificationTriggered(ctx, notification) // erreur journalisée, publication facultative
+	return nil
+}
+
+// envoi de la notification (journalisé en attendant un vrai canal e-mail/SMS)
+var sendNotification = func(userID, message string) error {
+	log.Printf("[Notification] -> %s : %s", userID, message)
+	return nil
+}
+
+// publication RabbitMQ
+var publishNotificationTriggered = func(ctx context.Context, notification models.Notification) error {
+	event := events.NotificationTriggeredEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeNotificationTriggered),
+		Payload: events.NotificationTriggeredPayload{
+			NotificationID: notification.ID,
+			UserID:         notification.UserID,
+			Message:        notification.Message,
+			CreatedAt:      notification.CreatedAt,
+		},
+	}
+
+	// événement informatif : aucune queue n'est tenue de le recevoir
+	err := publisher.PublishEvent(ctx, events.RoutingKeyNotificationTriggered, event, messaging.Optional())
+	if err != nil {
+		log.Println("[RabbitMQ] Erreur publication :", err)
+	}
+
+	return err
+}
@@ -0,0 +1,244 @@
+package business
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockDependencies remplace la base, l'envoi et la publication le temps d'un test.
+func mockDependencies(t *testing.T) (inserted, published *[]models.Notification) {
+	originalInsert := insertNotification
+	originalSend := sendNotification
+	originalPublisher := publishNotificationTriggered
+	t.Cleanup(func() {
+		insertNotification = originalInsert
+		sendNotification = originalSend
+		publishNotificationTriggered = originalPublisher
+	})
+
+	inserted, published = &[]models.Notification{}, &[]models.Notification{}
+	insertNotification = func(_ context.Context, n models.Notification) (bool, error) {
+		*inserted = append(*inserted, n)
+		return true, nil
+	}
+	sendNotification = func(_, _ string) error {
+		return nil
+	}
+	publishNotificationTriggered = func(_ context.Context, n models.Notification) error {
+		*published = append(*published, n)
+		return nil
+	}
+	return inserted, published
+}
+
+func TestHandleUserCreated_PersistsAndPublishes(t *testing.T) {
+	inserted, published := mockDependencies(t)
+
+	event := events.UserCreatedEvent{
+		BaseEvent: events.BaseEvent{EventType: events.TypeUserCreated, Version: "1.0", Timestamp: time.Now().UTC()},
+		Payload: events.UserCreatedPayload{
+			UserID:    "123e4567-e89b-12d3-a456-426614174000",
+			Username:  "lahoucine",
+			Email:     "lahoucine@example.com",
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+
+	err := Service{}.HandleUserCreated(context.Background(), event)
+	assert.NoError(t, err)
+
+	assert.Len(t, *inserted, 1)
+	assert.Len(t, *published, 1)
+	n := (*published)[0]
+	assert.Equal(t, (*inserted)[0].ID, n.ID)
+	assert.NotEmpty(t, n.ID)
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", n.UserID)
+	assert.Equal(t, events.TypeUserCreated, n.EventType)
+	assert.Contains(t, n.Message, "lahoucine")
+}
+
+func TestHandleUserUpdatedAndDeleted_Notify(t *testing.T) {
+	inserted, _ := mockDependencies(t)
+	userID := "123e4567-e89b-12d3-a456-426614174000"
+
+	updated := events.UserUpdatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserUpdated),
+		Payload: events.UserUpdatedPayload{
+			UserID:    userID,
+			Username:  "lahoucine",
+			Email:     "nouveau@example.com",
+			UpdatedAt: time.Now().UTC(),
+		},
+	}
+	deleted := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: userID, DeletedAt: time.Now().UTC()},
+	}
+
+	assert.NoError(t, Service{}.HandleUserUpdated(context.Background(), updated))
+	assert.NoError(t, Service{}.HandleUserDeleted(context.Background(), deleted))
+
+	if assert.Len(t, *inserted, 2) {
+		assert.Equal(t, events.TypeUserUpdated, (*inserted)[0].EventType)
+		assert.Contains(t, (*inserted)[0].Message, "nouveau@example.com")
+		assert.Equal(t, events.TypeUserDeleted, (*inserted)[1].EventType)
+		assert.Equal(t, userID, (*inserted)[1].UserID)
+	}
+}
+
+func TestHandleOrderCreated_SendErrorSkipsPublish(t *testing.T) {
+	_, published := mockDependencies(t)
+
+	sendNotification = func(_, _ string) error {
+		return assert.AnError
+	}
+
+	event := events.OrderCreatedEvent{
+		BaseEvent: events.BaseEvent{EventType: events.TypeOrderCreated, Version: "4.0", Timestamp: time.Now().UTC()},
+		Payload: events.OrderCreatedPayload{
+			OrderID:     "11111111-1111-1111-1111-111111111111",
+			UserID:      "123e4567-e89b-12d3-a456-426614174000",
+			TotalAmount: 3999,
+			Currency:    "EUR",
+			OrderDate:   time.Now().UTC(),
+		},
+	}
+
+	err := Service{}.HandleOrderCreated(context.Background(), event)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, *published)
+}
+
+func TestHandleOrderCreated_DBErrorSkipsSend(t *testing.T) {
+	_, published := mockDependencies(t)
+
+	insertNotification = func(context.Context, models.Notification) (bool, error) {
+		return false, assert.AnError
+	}
+	sendNotification = func(_, _ string) error {
+		t.Fatal("la notification ne doit pas être envoyée si elle n'a pas été enregistrée")
+		return nil
+	}
+
+	event := events.OrderCreatedEvent{
+		BaseEvent: events.BaseEvent{EventType: events.TypeOrderCreated, Version: "4.0", Timestamp: time.Now().UTC()},
+		Payload:   events.OrderCreatedPayload{OrderID: "11111111-1111-1111-1111-111111111111"},
+	}
+
+	err := Service{}.HandleOrderCreated(context.Background(), event)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, *published)
+}
+
+func TestHandleOrderCanceled_IncludesReason(t *testing.T) {
+	inserted, published := mockDependencies(t)
+
+	event := events.OrderCanceledEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderCanceled),
+		Payload: events.OrderCanceledPayload{
+			OrderID:    "11111111-1111-1111-1111-111111111111",
+			UserID:     "123e4567-e89b-12d3-a456-426614174000",
+			CanceledAt: time.Now().UTC(),
+			Reason:     "rupture de stock",
+		},
+	}
+
+	err := Service{}.HandleOrderCanceled(context.Background(), event)
+	assert.NoError(t, err)
+
+	if assert.Len(t, *inserted, 1) {
+		assert.Equal(t, events.TypeOrderCanceled, (*inserted)[0].EventType)
+		assert.Contains(t, (*inserted)[0].Message, "rupture de stock")
+	}
+	assert.Len(t, *published, 1)
+}
+
+func TestHandleOrderUpdated_CanceledIsNotifiedByOrderCanceled(t *testing.T) {
+	inserted, published := mockDependencies(t)
+
+	event := events.OrderUpdatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderUpdated),
+		Payload: events.OrderUpdatedPayload{
+			OrderID: "11111111-1111-1111-1111-111111111111",
+			UserID:  "123e4567-e89b-12d3-a456-426614174000",
+			Status:  "annulee",
+		},
+	}
+
+	err := Service{}.HandleOrderUpdated(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Empty(t, *inserted)
+	assert.Empty(t, *published)
+}
+
+func TestHandleOrderCreated_FormatsAmountInCurrency(t *testing.T) {
+	inserted, _ := mockDependencies(t)
+
+	event := events.OrderCreatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeOrderCreated),
+		Payload: events.OrderCreatedPayload{
+			OrderID:     "11111111-1111-1111-1111-111111111111",
+			UserID:      "123e4567-e89b-12d3-a456-426614174000",
+			TotalAmount: 3999,
+			Currency:    "EUR",
+			OrderDate:   time.Now().UTC(),
+		},
+	}
+
+	err := Service{}.HandleOrderCreated(context.Background(), event)
+	assert.NoError(t, err)
+
+	if assert.Len(t, *inserted, 1) {
+		assert.Contains(t, (*inserted)[0].Message, "39.99 EUR")
+	}
+}
+
+func TestNotify_AlreadyNotifiedEventIsSkipped(t *testing.T) {
+	_, published := mockDependencies(t)
+
+	insertNotification = func(context.Context, models.Notification) (bool, error) {
+		return false, nil // notification déjà enregistrée pour cet eventID
+	}
+	sendNotification = func(_, _ string) error {
+		t.Fatal("un événement déjà notifié ne doit pas être renvoyé")
+		return nil
+	}
+
+	event := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: "123e4567-e89b-12d3-a456-426614174000", DeletedAt: time.Now().UTC()},
+	}
+
+	assert.NoError(t, Service{}.HandleUserDeleted(context.Background(), event))
+	assert.Empty(t, *published)
+}
+
+func TestNotify_PublishErrorAfterSendSucceeds(t *testing.T) {
+	inserted, _ := mockDependencies(t)
+
+	sent := 0
+	sendNotification = func(_, _ string) error {
+		sent++
+		return nil
+	}
+	publishNotificationTriggered = func(context.Context, models.Notification) error {
+		return assert.AnError
+	}
+
+	event := events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload:   events.UserDeletedPayload{UserID: "123e4567-e89b-12d3-a456-426614174000", DeletedAt: time.Now().UTC()},
+	}
+
+	// l'utilisateur est notifié : l'événement ne doit pas être rejoué
+	assert.NoError(t, Service{}.HandleUserDeleted(context.Background(), event))
+	assert.Equal(t, 1, sent)
+	if assert.Len(t, *inserted, 1) {
+		assert.Equal(t, event.EventID, (*inserted)[0].EventID)
+	}
+}
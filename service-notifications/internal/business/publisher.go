@@ -0,0 +1,14 @@
+package business
+
+import (
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/messaging"
+)
+
+// publisher publie les événements NotificationTriggered ; c'est le
+// Publisher partagé du service, ouvert par messaging.Open.
+var publisher *messaging.Publisher
+
+// UsePublisher définit le Publisher du service.
+func UsePublisher(p *messaging.Publisher) {
+	publisher = p
+}
@@ -0,0 +1,17 @@
+package business
+
+import (
+	"context"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+)
+
+// NotificationService définit les réactions du service aux événements reçus.
+type NotificationService interface {
+	HandleUserCreated(ctx context.Context, event events.UserCreatedEvent) error
+	HandleUserUpdated(ctx context.Context, event events.UserUpdatedEvent) error
+	HandleUserDeleted(ctx context.Context, event events.UserDeletedEvent) error
+	HandleOrderCreated(ctx context.Context, event events.OrderCreatedEvent) error
+	HandleOrderUpdated(ctx context.Context, event events.OrderUpdatedEvent) error
+	HandleOrderCanceled(ctx context.Context, event events.OrderCanceledEvent) error
+}
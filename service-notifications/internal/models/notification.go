@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Notification représente un message envoyé à un utilisateur.
+type Notification struct {
+	ID        string    `json:"id"`
+	EventID   string    `json:"event_id,omitempty"` // événement à l'origine de la notification
+	UserID    string    `json:"user_id"`
+	EventType string    `json:"event_type"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
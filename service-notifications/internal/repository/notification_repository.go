@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/consumer"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/models"
+	_ "github.com/lib/pq"
+)
+
+var db *sql.DB
+
+// InitDB initialise la connexion à la base de données PostgreSQL.
+func InitDB() error {
+	var err error
+	db, err = sql.Open("postgres", os.Getenv("POSTGRES_CONN"))
+	if err != nil {
+		log.Println("[DB] Connexion échouée :", err)
+		return err
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Println("[DB] Ping échoué :", err)
+		return err
+	}
+
+	log.Println("[DB] Connexion réussie")
+	return nil
+}
+
+// DB retourne la connexion partagée (utilisée par la déduplication des
+// événements consommés).
+func DB() *sql.DB {
+	return db
+}
+
+// execer est satisfait par *sql.DB et *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// InsertNotification enregistre une notification, dans la transaction de
+// déduplication de l'événement consommé s'il y en a une. Une seule
+// notification est enregistrée par EventID : inserted vaut false si elle
+// existe déjà.
+func InsertNotification(ctx context.Context, n models.Notification) (inserted bool, err error) {
+	ctx, span := tracing.StartDB(ctx, "InsertNotification")
+	defer tracing.End(span, &err)
+
+	var exec execer = db
+	if tx := consumer.Tx(ctx); tx != nil {
+		exec = tx
+	}
+
+	res, err := exec.ExecContext(ctx, `
+		INSERT INTO notifications (id, event_id, user_id, event_type, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_id) DO NOTHING
+	`, n.ID, sql.NullString{String: n.EventID, Valid: n.EventID != ""}, n.UserID, n.EventType, n.Message, n.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	n64, err := res.RowsAffected()
+	return n64 == 1, err
+}
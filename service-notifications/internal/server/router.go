@@ -0,0 +1,24 @@
+package server
+
+import (
+	"os"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/dlq"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-notifications/internal/api"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRouter configure les routes HTTP du service notifications :
+// l'administration des dead-letter queues, réservée au jeton ADMIN_TOKEN.
+func SetupRouter(admin *dlq.Admin) *gin.Engine {
+	router := gin.Default()
+	router.ContextWithFallback = true
+	router.Use(tracing.Middleware())
+
+	router.GET("/health", api.HealthHandler)
+
+	admin.Register(router.Group("/admin", api.AdminOnly(os.Getenv("ADMIN_TOKEN"))))
+
+	return router
+}
@@ -0,0 +1,12 @@
+-- Déduplication des événements consommés (voir initdb/init.sql).
+--
+--   psql "$POSTGRES_CONN" -f migrations/001_processed_messages.sql
+
+CREATE TABLE IF NOT EXISTS processed_messages (
+  consumer_group TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  processed_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (consumer_group, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS processed_messages_processed_idx ON processed_messages (processed_at);
@@ -0,0 +1,6 @@
+-- Événement à l'origine de chaque notification, unique (voir initdb/init.sql).
+-- Les notifications existantes restent sans event_id.
+--
+--   psql "$POSTGRES_CONN" -f migrations/002_notifications_event_id.sql
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS event_id TEXT UNIQUE;
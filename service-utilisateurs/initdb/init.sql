@@ -4,3 +4,34 @@ CREATE TABLE IF NOT EXISTS users (
   email TEXT NOT NULL,
   created_at TIMESTAMP NOT NULL
 );
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id UUID PRIMARY KEY,
+  exchange TEXT NOT NULL,
+  routing_key TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  headers JSONB,
+  created_at TIMESTAMP NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_error TEXT,
+  sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
+
+-- Clés Idempotency-Key des POST : empreinte de la requête et réponse
+-- d'origine, rejouée tant que la clé n'a pas expiré. Une requête en cours
+-- verrouille la clé jusqu'à locked_until.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  key TEXT PRIMARY KEY,
+  fingerprint TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP,
+  status_code INT,
+  content_type TEXT,
+  body BYTEA,
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_idx ON idempotency_keys (created_at);
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -17,6 +18,12 @@ type CreateUserInput struct {
 	Email    string `json:"email" binding:"required,email"`
 }
 
+// UpdateUserInput représente les données envoyées dans le PUT /users/:id
+type UpdateUserInput struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+}
+
 // Handler structure injectée avec un service
 type Handler struct {
 	UserService business.UserService
@@ -51,6 +58,95 @@ func (h *Handler) CreateUserHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, user)
 }
 
+// GetAllUsersHandler traite GET /users
+func (h *Handler) GetAllUsersHandler(c *gin.Context) {
+	users, err := h.UserService.GetAllUsers(c)
+	if err != nil {
+		log.Println("[Handler] Erreur récupération utilisateurs :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUserByIDHandler traite GET /users/:id
+func (h *Handler) GetUserByIDHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	user, err := h.UserService.GetUserByID(c, id)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		log.Println("[Handler] Erreur récupération utilisateur :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserHandler traite PUT /users/:id
+func (h *Handler) UpdateUserHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	var input UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := models.User{
+		Username: input.Username,
+		Email:    input.Email,
+	}
+
+	user, err := h.UserService.UpdateUser(c, id, update)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		log.Println("[Handler] Erreur mise à jour utilisateur :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUserHandler traite DELETE /users/:id
+func (h *Handler) DeleteUserHandler(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid UUID"})
+		return
+	}
+
+	err := h.UserService.DeleteUser(c, id)
+	if errors.Is(err, business.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		log.Println("[Handler] Erreur suppression utilisateur :", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+}
+
 // HealthHandler traite GET /health
 func HealthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
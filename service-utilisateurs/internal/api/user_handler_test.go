@@ -7,12 +7,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/business"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/models"
 )
 
+var mockID = "11111111-1111-1111-1111-111111111111"
+
 // fakeUserService mocke l’interface business.UserService pour les tests
 type fakeUserService struct{}
 
@@ -20,25 +24,79 @@ func (f fakeUserService) CreateUser(_ context.Context, _ models.User) error {
 	return nil
 }
 
+func (f fakeUserService) GetAllUsers(_ context.Context) ([]models.User, error) {
+	return []models.User{
+		{
+			ID:        mockID,
+			Username:  "lahoucine",
+			Email:     "lahoucine@example.com",
+			CreatedAt: time.Now().UTC(),
+		},
+	}, nil
+}
+
+func (f fakeUserService) GetUserByID(_ context.Context, id string) (*models.User, error) {
+	if id != mockID {
+		return nil, business.ErrNotFound
+	}
+	return &models.User{
+		ID:        mockID,
+		Username:  "lahoucine",
+		Email:     "lahoucine@example.com",
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+func (f fakeUserService) UpdateUser(_ context.Context, id string, update models.User) (*models.User, error) {
+	if id != mockID {
+		return nil, business.ErrNotFound
+	}
+	update.ID = id
+	return &update, nil
+}
+
+func (f fakeUserService) DeleteUser(_ context.Context, id string) error {
+	if id != mockID {
+		return business.ErrNotFound
+	}
+	return nil
+}
+
+// failingUserService échoue sur toutes les opérations.
 type failingUserService struct{}
 
 func (f failingUserService) CreateUser(_ context.Context, _ models.User) error {
 	return assert.AnError
 }
 
-type failingDBService struct{}
+func (f failingUserService) GetAllUsers(_ context.Context) ([]models.User, error) {
+	return nil, assert.AnError
+}
+
+func (f failingUserService) GetUserByID(_ context.Context, _ string) (*models.User, error) {
+	return nil, assert.AnError
+}
+
+func (f failingUserService) UpdateUser(_ context.Context, _ string, _ models.User) (*models.User, error) {
+	return nil, assert.AnError
+}
+
+func (f failingUserService) DeleteUser(_ context.Context, _ string) error {
+	return assert.AnError
+}
+
+type failingDBService struct{ fakeUserService }
 
 func (f failingDBService) CreateUser(_ context.Context, _ models.User) error {
 	return assert.AnError // Simule une erreur métier (ex : DB down)
 }
 
-type failingMQService struct{}
+type failingMQService struct{ fakeUserService }
 
 func (f failingMQService) CreateUser(_ context.Context, _ models.User) error {
 	return assert.AnError // Simule une erreur lors de la publication
 }
 
-
 func TestCreateUserHandler_Mock(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -88,7 +146,6 @@ func TestCreateUserHandler_ServiceFails(t *testing.T) {
 	assert.Equal(t, "could not create user", response["error"])
 }
 
-
 func TestCreateUserHandler_DBError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -216,3 +273,156 @@ func TestCreateUserHandler_InvalidJSON(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 }
+
+func TestGetAllUsersHandler(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var users []models.User
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &users))
+	assert.Len(t, users, 1)
+}
+
+func TestGetUserByIDHandler_Success(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/"+mockID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestGetUserByIDHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/22222222-2222-2222-2222-222222222222", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestGetUserByIDHandler_InvalidUUID(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/pas-un-uuid", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestGetUserByIDHandler_ServiceFails(t *testing.T) {
+	router := setupRouterWith(failingUserService{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/users/"+mockID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+}
+
+func TestUpdateUserHandler_Success(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "lahoucine2",
+		"email":    "nouveau@example.com",
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/users/"+mockID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var user models.User
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &user))
+	assert.Equal(t, mockID, user.ID)
+	assert.Equal(t, "nouveau@example.com", user.Email)
+}
+
+func TestUpdateUserHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "lahoucine2",
+		"email":    "nouveau@example.com",
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/users/22222222-2222-2222-2222-222222222222", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestUpdateUserHandler_InvalidEmail(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "lahoucine2",
+		"email":    "not-an-email",
+	})
+
+	req, _ := http.NewRequest(http.MethodPut, "/users/"+mockID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestDeleteUserHandler_Success(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodDelete, "/users/"+mockID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestDeleteUserHandler_NotFound(t *testing.T) {
+	router := setupRouterWith(fakeUserService{})
+
+	req, _ := http.NewRequest(http.MethodDelete, "/users/22222222-2222-2222-2222-222222222222", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestDeleteUserHandler_ServiceFails(t *testing.T) {
+	router := setupRouterWith(failingUserService{})
+
+	req, _ := http.NewRequest(http.MethodDelete, "/users/"+mockID, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	assert.Equal(t, "could not delete user", response["error"])
+}
+
+// setupRouterWith est une fonction utilitaire locale aux tests
+func setupRouterWith(service business.UserService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	handler := NewHandler(service)
+
+	router.POST("/users", handler.CreateUserHandler)
+	router.GET("/users", handler.GetAllUsersHandler)
+	router.GET("/users/:id", handler.GetUserByIDHandler)
+	router.PUT("/users/:id", handler.UpdateUserHandler)
+	router.DELETE("/users/:id", handler.DeleteUserHandler)
+
+	return router
+}
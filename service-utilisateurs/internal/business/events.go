@@ -0,0 +1,48 @@
+package business
+
+import (
+	"time"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/models"
+)
+
+// Correspondance entre le modèle interne et les contrats de common/events :
+// les consommateurs ne dépendent que du contrat, jamais de models.User.
+
+// toUserCreatedEvent traduit un utilisateur en événement UserCreated.
+func toUserCreatedEvent(u models.User) events.UserCreatedEvent {
+	return events.UserCreatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserCreated),
+		Payload: events.UserCreatedPayload{
+			UserID:    u.ID,
+			Username:  u.Username,
+			Email:     u.Email,
+			CreatedAt: u.CreatedAt,
+		},
+	}
+}
+
+// toUserUpdatedEvent traduit un utilisateur modifié en événement UserUpdated.
+func toUserUpdatedEvent(u models.User, updatedAt time.Time) events.UserUpdatedEvent {
+	return events.UserUpdatedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserUpdated),
+		Payload: events.UserUpdatedPayload{
+			UserID:    u.ID,
+			Username:  u.Username,
+			Email:     u.Email,
+			UpdatedAt: updatedAt,
+		},
+	}
+}
+
+// toUserDeletedEvent construit l'événement UserDeleted d'un utilisateur.
+func toUserDeletedEvent(id string, deletedAt time.Time) events.UserDeletedEvent {
+	return events.UserDeletedEvent{
+		BaseEvent: events.NewBaseEvent(events.TypeUserDeleted),
+		Payload: events.UserDeletedPayload{
+			UserID:    id,
+			DeletedAt: deletedAt,
+		},
+	}
+}
@@ -0,0 +1,11 @@
+package business
+
+import (
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/idempotency"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/repository"
+)
+
+// IdempotencyStore retourne le store des clés Idempotency-Key du service.
+func IdempotencyStore() idempotency.Store {
+	return idempotency.NewSQLStore(repository.DB())
+}
@@ -0,0 +1,20 @@
+package business
+
+import (
+	"context"
+
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/repository"
+)
+
+// StartOutboxRelay publie en arrière-plan, avec publisher, les événements
+// enregistrés dans l'outbox, jusqu'à l'annulation du contexte. Le canal
+// retourné est fermé une fois le relais arrêté.
+func StartOutboxRelay(ctx context.Context, publisher outbox.Publisher) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		outbox.NewRelay(repository.DB(), publisher).Run(ctx)
+	}()
+	return done
+}
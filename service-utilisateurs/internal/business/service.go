@@ -9,4 +9,8 @@ import (
 // UserService définit les opérations offertes par la couche métier.
 type UserService interface {
 	CreateUser(ctx context.Context, user models.User) error
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	UpdateUser(ctx context.Context, id string, update models.User) (*models.User, error)
+	DeleteUser(ctx context.Context, id string) error
 }
@@ -2,80 +2,79 @@ package business
 
 import (
 	"context"
-	"encoding/json"
-	"log"
-	"os"
 	"time"
 
-	"github.com/streadway/amqp"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/models"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/repository"
 )
 
-// UserCreatedEvent représente un message UserCreated publié dans RabbitMQ.
-type UserCreatedEvent struct {
-	EventType string        `json:"eventType"`
-	Version   string        `json:"version"`
-	Timestamp string        `json:"timestamp"`
-	Payload   models.User   `json:"payload"`
-}
-
 // Service est l’implémentation concrète de l’interface UserService.
 type Service struct{}
 
-var insertUser = repository.InsertUser
+// ErrNotFound est retournée lorsque l'utilisateur demandé n'existe pas.
+var ErrNotFound = repository.ErrNotFound
+
+var (
+	insertUser  = repository.InsertUser
+	getAllUsers = repository.GetAllUsers
+	getUserByID = repository.GetUserByID
+	updateUser  = repository.UpdateUser
+	deleteUser  = repository.DeleteUser
+)
 
-// CreateUser insère l'utilisateur en base et publie l'événement.
+// CreateUser insère l'utilisateur et son événement UserCreated dans la même
+// transaction ; le relais outbox se charge ensuite de la publication.
 func (s Service) CreateUser(ctx context.Context, user models.User) error {
-	if err := insertUser(user); err != nil {
+	msg, err := newUserCreatedMessage(ctx, user)
+	if err != nil {
 		return err
 	}
-	return publishUserCreated(user)
+	return insertUser(ctx, user, msg)
 }
 
+// GetAllUsers retourne tous les utilisateurs.
+func (s Service) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return getAllUsers(ctx)
+}
 
-var publishUserCreated = func(user models.User) error {
-	rabbitURL := os.Getenv("RABBITMQ_URL")
-	conn, err := amqp.Dial(rabbitURL)
+// GetUserByID retourne un utilisateur par ID.
+func (s Service) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	u, err := getUserByID(ctx, id)
 	if err != nil {
-		log.Println("[RabbitMQ] Connexion échouée :", err)
-		return err
+		return nil, err
 	}
-	defer conn.Close()
+	return &u, nil
+}
 
-	ch, err := conn.Channel()
+// UpdateUser met à jour un utilisateur existant et retourne sa version
+// enregistrée ; l'événement UserUpdated est écrit dans la même transaction.
+func (s Service) UpdateUser(ctx context.Context, id string, update models.User) (*models.User, error) {
+	update.ID = id // assurer que l’ID reste le même
+	msg, err := outbox.NewMessage(ctx, events.RoutingKeyUserUpdated, toUserUpdatedEvent(update, time.Now().UTC()))
 	if err != nil {
-		log.Println("[RabbitMQ] Échec ouverture channel :", err)
-		return err
-	}
-	defer ch.Close()
-
-	event := UserCreatedEvent{
-		EventType: "UserCreated",
-		Version:   "1.0",
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Payload:   user,
+		return nil, err
 	}
 
-	body, err := json.Marshal(event)
+	u, err := updateUser(ctx, update, msg)
 	if err != nil {
-		log.Println("[RabbitMQ] JSON invalide :", err)
-		return err
+		return nil, err
 	}
+	return &u, nil
+}
 
-	err = ch.Publish(
-		"events",         // exchange
-		"user.created",   // routing key
-		false, false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
-
+// DeleteUser supprime un utilisateur ; l'événement UserDeleted est écrit
+// dans la même transaction.
+func (s Service) DeleteUser(ctx context.Context, id string) error {
+	msg, err := outbox.NewMessage(ctx, events.RoutingKeyUserDeleted, toUserDeletedEvent(id, time.Now().UTC()))
 	if err != nil {
-		log.Println("[RabbitMQ] Erreur publication :", err)
+		return err
 	}
+	return deleteUser(ctx, id, msg)
+}
 
-	return err
+// newUserCreatedMessage prépare l'événement UserCreated pour l'outbox.
+func newUserCreatedMessage(ctx context.Context, user models.User) (outbox.Message, error) {
+	return outbox.NewMessage(ctx, events.RoutingKeyUserCreated, toUserCreatedEvent(user))
 }
@@ -2,9 +2,12 @@ package business
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/events"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -12,16 +15,18 @@ func TestCreateUser_MockRabbitMQ(t *testing.T) {
 	// 🔁 Mock InsertUser
 	originalInsertUser := insertUser
 	defer func() { insertUser = originalInsertUser }()
-	insertUser = func(user models.User) error {
+	insertUser = func(_ context.Context, user models.User, messages ...outbox.Message) error {
 		assert.Equal(t, "testuser@example.com", user.Email)
-		return nil
-	}
 
-	// 🔁 Mock publishUserCreated
-	originalPublisher := publishUserCreated
-	defer func() { publishUserCreated = originalPublisher }()
-	publishUserCreated = func(user models.User) error {
-		assert.Equal(t, "lahoucine", user.Username)
+		// l'événement est écrit dans l'outbox avec l'utilisateur
+		if assert.Len(t, messages, 1) {
+			var event events.UserCreatedEvent
+			assert.Equal(t, "user.created", messages[0].RoutingKey)
+			assert.NoError(t, json.Unmarshal(messages[0].Payload, &event))
+			assert.Equal(t, events.TypeUserCreated, event.EventType)
+			assert.Equal(t, "test-id", event.Payload.UserID)
+			assert.Equal(t, "lahoucine", event.Payload.Username)
+		}
 		return nil
 	}
 
@@ -37,3 +42,69 @@ func TestCreateUser_MockRabbitMQ(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestUpdateUser_KeepsIDAndWritesEvent(t *testing.T) {
+	originalUpdateUser := updateUser
+	defer func() { updateUser = originalUpdateUser }()
+
+	updateUser = func(_ context.Context, user models.User, messages ...outbox.Message) (models.User, error) {
+		assert.Equal(t, "test-id", user.ID)
+		if assert.Len(t, messages, 1) {
+			var event events.UserUpdatedEvent
+			assert.Equal(t, "user.updated", messages[0].RoutingKey)
+			assert.NoError(t, json.Unmarshal(messages[0].Payload, &event))
+			assert.Equal(t, events.TypeUserUpdated, event.EventType)
+			assert.Equal(t, "test-id", event.Payload.UserID)
+			assert.Equal(t, "nouveau@example.com", event.Payload.Email)
+			assert.False(t, event.Payload.UpdatedAt.IsZero())
+		}
+		return user, nil
+	}
+
+	update := models.User{ID: "autre-id", Username: "lahoucine", Email: "nouveau@example.com"}
+	updated, err := Service{}.UpdateUser(context.Background(), "test-id", update)
+	assert.NoError(t, err)
+	assert.Equal(t, "test-id", updated.ID)
+}
+
+func TestDeleteUser_WritesEvent(t *testing.T) {
+	originalDeleteUser := deleteUser
+	defer func() { deleteUser = originalDeleteUser }()
+
+	deleteUser = func(_ context.Context, id string, messages ...outbox.Message) error {
+		assert.Equal(t, "test-id", id)
+		if assert.Len(t, messages, 1) {
+			var event events.UserDeletedEvent
+			assert.Equal(t, "user.deleted", messages[0].RoutingKey)
+			assert.NoError(t, json.Unmarshal(messages[0].Payload, &event))
+			assert.Equal(t, events.TypeUserDeleted, event.EventType)
+			assert.Equal(t, "test-id", event.Payload.UserID)
+		}
+		return nil
+	}
+
+	assert.NoError(t, Service{}.DeleteUser(context.Background(), "test-id"))
+}
+
+func TestDeleteUser_NotFound(t *testing.T) {
+	originalDeleteUser := deleteUser
+	defer func() { deleteUser = originalDeleteUser }()
+
+	deleteUser = func(_ context.Context, _ string, _ ...outbox.Message) error {
+		return ErrNotFound
+	}
+
+	assert.ErrorIs(t, Service{}.DeleteUser(context.Background(), "inconnu"), ErrNotFound)
+}
+
+func TestGetUserByID_NotFound(t *testing.T) {
+	originalGetUserByID := getUserByID
+	defer func() { getUserByID = originalGetUserByID }()
+
+	getUserByID = func(_ context.Context, _ string) (models.User, error) {
+		return models.User{}, ErrNotFound
+	}
+
+	user, err := Service{}.GetUserByID(context.Background(), "inconnu")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, user)
+}
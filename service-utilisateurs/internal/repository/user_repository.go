@@ -1,16 +1,23 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"os"
 
 	_ "github.com/lib/pq"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/outbox"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/models"
 )
 
 var db *sql.DB
 
+// ErrNotFound est retournée lorsqu'aucun utilisateur ne correspond à l'ID.
+var ErrNotFound = errors.New("user not found")
+
 // InitDB initialise la connexion à la base de données.
 func InitDB() error {
 	var err error
@@ -29,11 +36,134 @@ func InitDB() error {
 	return nil
 }
 
-// InsertUser insère un utilisateur dans la base.
-func InsertUser(u models.User) error {
-	_, err := db.Exec(`
+// DB retourne la connexion partagée (utilisée par le relais outbox).
+func DB() *sql.DB {
+	return db
+}
+
+// InsertUser insère un utilisateur et ses événements dans l'outbox, au sein
+// d'une même transaction.
+func InsertUser(ctx context.Context, u models.User, messages ...outbox.Message) (err error) {
+	ctx, span := tracing.StartDB(ctx, "InsertUser")
+	defer tracing.End(span, &err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
 		INSERT INTO users (id, username, email, created_at)
 		VALUES ($1, $2, $3, $4)
 	`, u.ID, u.Username, u.Email, u.CreatedAt)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := outbox.Enqueue(tx, messages...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetAllUsers retourne tous les utilisateurs.
+func GetAllUsers(ctx context.Context) (_ []models.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetAllUsers")
+	defer tracing.End(span, &err)
+
+	rows, err := db.QueryContext(ctx, `SELECT id, username, email, created_at FROM users ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUserByID retourne un utilisateur par ID, ou ErrNotFound.
+func GetUserByID(ctx context.Context, id string) (_ models.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "GetUserByID")
+	defer tracing.End(span, &err)
+
+	var u models.User
+	err = db.QueryRowContext(ctx, `
+		SELECT id, username, email, created_at
+		FROM users WHERE id = $1
+	`, id).Scan(&u.ID, &u.Username, &u.Email, &u.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+// UpdateUser met à jour un utilisateur et enregistre ses événements dans
+// l'outbox au sein d'une même transaction. Retourne la version enregistrée,
+// ou ErrNotFound (aucun événement n'est alors écrit).
+func UpdateUser(ctx context.Context, u models.User, messages ...outbox.Message) (_ models.User, err error) {
+	ctx, span := tracing.StartDB(ctx, "UpdateUser")
+	defer tracing.End(span, &err)
+
+	var updated models.User
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return updated, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users
+		SET username = $1, email = $2
+		WHERE id = $3
+		RETURNING id, username, email, created_at
+	`, u.Username, u.Email, u.ID).Scan(&updated.ID, &updated.Username, &updated.Email, &updated.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return updated, ErrNotFound
+	}
+	if err != nil {
+		return updated, err
+	}
+
+	if err := outbox.Enqueue(tx, messages...); err != nil {
+		return updated, err
+	}
+	return updated, tx.Commit()
+}
+
+// DeleteUser supprime un utilisateur et enregistre ses événements dans
+// l'outbox au sein d'une même transaction, ou retourne ErrNotFound.
+func DeleteUser(ctx context.Context, id string, messages ...outbox.Message) (err error) {
+	ctx, span := tracing.StartDB(ctx, "DeleteUser")
+	defer tracing.End(span, &err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	if err := outbox.Enqueue(tx, messages...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
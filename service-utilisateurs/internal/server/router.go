@@ -2,6 +2,9 @@ package server
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/correlation"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/idempotency"
+	"github.com/Lahoucine-7/microservices_asynchrones_go/common/tracing"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/api"
 	"github.com/Lahoucine-7/microservices_asynchrones_go/service-utilisateurs/internal/business"
 )
@@ -9,11 +12,21 @@ import (
 // SetupRouter configure les routes HTTP.
 func SetupRouter() *gin.Engine {
 	router := gin.Default()
+	// les handlers passent *gin.Context aux services comme context.Context
+	router.ContextWithFallback = true
+	router.Use(correlation.Middleware(), tracing.Middleware())
 
 	router.GET("/health", api.HealthHandler)
 
 	handler := api.NewHandler(business.Service{}) // ← instance réelle ici
-	router.POST("/users", handler.CreateUserHandler)
+	idempotent := idempotency.Middleware(business.IdempotencyStore(), idempotency.RetentionFromEnv())
+
+	// Routes REST
+	router.POST("/users", idempotent, handler.CreateUserHandler)
+	router.GET("/users", handler.GetAllUsersHandler)
+	router.GET("/users/:id", handler.GetUserByIDHandler)
+	router.PUT("/users/:id", handler.UpdateUserHandler)
+	router.DELETE("/users/:id", handler.DeleteUserHandler)
 
 	return router
 }
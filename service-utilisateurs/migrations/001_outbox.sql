@@ -0,0 +1,17 @@
+-- Outbox des événements publiés par le relay (voir initdb/init.sql).
+--
+--   psql "$POSTGRES_CONN" -f migrations/001_outbox.sql
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id UUID PRIMARY KEY,
+  exchange TEXT NOT NULL,
+  routing_key TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_error TEXT,
+  sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;